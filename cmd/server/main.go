@@ -2,29 +2,43 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
-	"github.com/go-chi/cors"
-
+	"mikrotik-parser-go/internal/catalog"
 	"mikrotik-parser-go/internal/config"
 	httpapi "mikrotik-parser-go/internal/http"
 	imigrate "mikrotik-parser-go/internal/migrate"
 	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/mikrotik/fake"
 	"mikrotik-parser-go/internal/service"
 	"mikrotik-parser-go/internal/storage"
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "run against an in-process fake router with demo data")
+	flag.Parse()
+
 	cfg := config.Load()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if *demo {
+		srv, err := startDemo(ctx, &cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer srv.Close()
+	}
+
 	if err := imigrate.Up(cfg.SqliteDSN); err != nil {
 		log.Fatal(err)
 	}
@@ -35,26 +49,98 @@ func main() {
 	}
 	defer pg.Close()
 
-	mt := mikrotik.New(cfg.MikrotikAddr, cfg.MikrotikUser, cfg.MikrotikPass)
-	defer mt.Close()
+	services, err := catalog.Load(cfg.ServicesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ignoreMatch, err := service.ParseMatchMode(cfg.IgnoreVPNMatch)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	broker := service.NewBroker()
 
-	connectionsSvc := service.NewConnectionsService(mt, cfg.IgnoreVPNListName, cfg.IgnoreLanToVpnListName)
-	collectSvc := service.NewCollectService(connectionsSvc, pg, cfg.CollectInterval)
+	routers := make([]*service.Router, 0, len(cfg.Routers))
+	for _, rc := range cfg.Routers {
+		mt, err := newRouterClient(rc)
+		if err != nil {
+			log.Fatalf("router %s: %v", rc.Name, err)
+		}
+		defer mt.Close()
 
-	go collectSvc.Run(ctx)
+		if rc.Stream {
+			if c, ok := mt.(*mikrotik.Client); ok {
+				c.Follow(ctx, mikrotik.StreamPaths(rc.IPv6), rc.StreamResync)
+			} else {
+				log.Printf("router %s: streaming needs the binary API, using periodic print", rc.Name)
+			}
+		}
 
-	h := httpapi.NewHandler(connectionsSvc, collectSvc, cfg.StaticDir)
-	handler := cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	})(h.Router())
+		connectionsSvc := service.NewConnectionsService(rc.Name, mt, cfg.IgnoreVPNListName, cfg.IgnoreLanToVpnListName, rc.IPv6)
+		connectionsSvc.UseDNSMemory(pg, cfg.DNSMemory)
+		connectionsSvc.UseCatalog(services)
+		connectionsSvc.UseIgnoreVPNMatch(ignoreMatch)
+		connectionsSvc.UseAddressLists(cfg.AddressLists)
+		connectionsSvc.UseAudit(pg)
+		connectionsSvc.UseSnapshots(pg)
+		if cfg.SnapshotInterval > 0 {
+			go connectionsSvc.RunSnapshots(ctx, cfg.SnapshotInterval)
+		}
+		collectSvc := service.NewCollectService(connectionsSvc, pg, cfg.CollectInterval)
+		collectSvc.UseBroker(broker)
+
+		go collectSvc.Run(ctx)
+
+		routers = append(routers, &service.Router{
+			Name:        rc.Name,
+			Client:      mt,
+			Connections: connectionsSvc,
+			Collect:     collectSvc,
+		})
+	}
+
+	retentionSvc := service.NewRetentionService(pg, service.RetentionPolicy{
+		History:   cfg.HistoryRetention,
+		Counts:    cfg.CountsRetention,
+		DNS:       cfg.DNSRetention,
+		Audit:     cfg.AuditRetention,
+		Snapshots: cfg.SnapshotRetention,
+		Rollups: map[storage.Bucket]time.Duration{
+			storage.BucketMinute: cfg.RollupMinuteRetention,
+			storage.BucketHour:   cfg.RollupHourRetention,
+			storage.BucketDay:    cfg.RollupDayRetention,
+		},
+		Batch: cfg.PruneBatch,
+	}, cfg.PruneInterval)
+	go retentionSvc.Run(ctx)
+
+	var authSvc *service.AuthService
+	if cfg.AuthDisabled {
+		log.Println("WARNING: API authentication is disabled (APP_AUTH_DISABLED)")
+	} else {
+		authSvc = service.NewAuthService(pg, cfg.SessionTTL)
+		created, err := authSvc.Bootstrap(ctx, cfg.AdminUser, cfg.AdminPassword)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if created {
+			log.Printf("created admin user %q", cfg.AdminUser)
+		} else if n, err := pg.CountUsers(ctx); err != nil {
+			log.Fatal(err)
+		} else if n == 0 {
+			// без пользователей API отвечал бы 401 на всё — падаем сразу, а не после обновления
+			log.Fatal("no API users: set APP_ADMIN_PASSWORD to create the first admin, or APP_AUTH_DISABLED=true to run without authentication")
+		}
+	}
+
+	h := httpapi.NewHandler(routers, retentionSvc, broker, authSvc, cfg.StaticDir)
+	h.UseCORS(cfg.CORSOrigins)
+	h.UseSecureCookie(cfg.CookieSecure)
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.HTTPPort,
-		Handler:           handler,
+		Handler:           h.Router(),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
@@ -70,7 +156,64 @@ func main() {
 	<-ch
 
 	cancel()
+	broker.Close() // отпускаем открытые потоки, иначе Shutdown их ждёт
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelShutdown()
 	_ = srv.Shutdown(ctxShutdown)
 }
+
+func newRouterClient(rc config.RouterConfig) (mikrotik.API, error) {
+	tlsOpts := mikrotik.TLSOptions{
+		CAFile:      rc.TLSCAFile,
+		Fingerprint: rc.TLSFingerprint,
+		Insecure:    rc.TLSInsecure,
+	}
+
+	switch rc.API {
+	case "", "api":
+		if !rc.TLS {
+			return mikrotik.New(rc.Addr, rc.User, rc.Pass), nil
+		}
+		tlsConfig, err := tlsOpts.Config()
+		if err != nil {
+			return nil, err
+		}
+		return mikrotik.NewTLS(rc.Addr, rc.User, rc.Pass, tlsConfig), nil
+	case "rest":
+		tlsConfig, err := tlsOpts.Config()
+		if err != nil {
+			return nil, err
+		}
+		return mikrotik.NewRest(rc.Addr, rc.User, rc.Pass, tlsConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown router API %q (want api or rest)", rc.API)
+	}
+}
+
+// startDemo поднимает фейковый роутер с демо-данными и подменяет им роутеры из конфига.
+func startDemo(ctx context.Context, cfg *config.Config) (*fake.Server, error) {
+	srv := fake.New()
+	fake.SeedDemo(srv)
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	go srv.Churn(ctx, 5*time.Second)
+
+	demo := config.RouterConfig{Name: "demo", Addr: srv.Addr(), User: "admin", IPv6: true}
+	if len(cfg.Routers) > 0 {
+		// потоковый режим — как задан для роутера по умолчанию (APP_MIKROTIK_STREAM)
+		demo.Stream, demo.StreamResync = cfg.Routers[0].Stream, cfg.Routers[0].StreamResync
+	}
+	cfg.Routers = []config.RouterConfig{demo}
+	if cfg.AdminPassword == "" {
+		cfg.AdminPassword = "admin"
+		log.Printf("demo mode: API user %q, password %q", cfg.AdminUser, cfg.AdminPassword)
+	}
+	// демо-данные не должны попасть в рабочую базу: APP_SQLITE_DSN в демо-режиме не используется
+	if cfg.SqliteDSN != "" {
+		log.Println("demo mode: APP_SQLITE_DSN is ignored")
+	}
+	cfg.SqliteDSN = "file:" + filepath.ToSlash(filepath.Join(os.TempDir(), "mikrotik_parser_demo.sqlite"))
+	log.Println("demo mode: fake router on", srv.Addr(), "db", cfg.SqliteDSN)
+	return srv, nil
+}
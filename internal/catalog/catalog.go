@@ -0,0 +1,115 @@
+// Package catalog — каталог сервисов: какие домены и подсети относятся к «Netflix», «Telegram» и т.п.
+//
+// Встроенный список (services.json) дополняется файлом пользователя в том же формате;
+// сервис с тем же именем из файла заменяет встроенный целиком.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+//go:embed services.json
+var defaultServices []byte
+
+type Service struct {
+	Name    string         `json:"name"`
+	Domains []string       `json:"domains"` // суффиксы: netflix.com покрывает и www.netflix.com
+	CIDRs   []netip.Prefix `json:"cidrs,omitempty"`
+}
+
+type Catalog struct {
+	services []Service
+}
+
+// Load читает встроенный каталог и, если path не пустой, файл пользователя.
+func Load(path string) (*Catalog, error) {
+	var services []Service
+	if err := json.Unmarshal(defaultServices, &services); err != nil {
+		return nil, fmt.Errorf("embedded services: %w", err)
+	}
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var user []Service
+		if err := json.Unmarshal(b, &user); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, u := range user {
+			i := slices.IndexFunc(services, func(s Service) bool { return strings.EqualFold(s.Name, u.Name) })
+			if i >= 0 {
+				services[i] = u
+			} else {
+				services = append(services, u)
+			}
+		}
+	}
+
+	for i := range services {
+		s := &services[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("service #%d: empty name", i+1)
+		}
+		for j, d := range s.Domains {
+			s.Domains[j] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+		}
+		for j, p := range s.CIDRs {
+			s.CIDRs[j] = p.Masked()
+		}
+	}
+	return &Catalog{services: services}, nil
+}
+
+func (c *Catalog) Services() []Service { return c.services }
+
+// Get ищет сервис по имени без учёта регистра.
+func (c *Catalog) Get(name string) (Service, bool) {
+	for _, s := range c.services {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return Service{}, false
+}
+
+// Match — сервис соединения: сначала по домену (самый длинный совпавший суффикс),
+// затем по адресу (самый узкий префикс). "" — не найден.
+func (c *Catalog) Match(domain, ip string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	best, bestLen := "", 0
+	if domain != "" {
+		for _, s := range c.services {
+			for _, d := range s.Domains {
+				if len(d) > bestLen && (domain == d || strings.HasSuffix(domain, "."+d)) {
+					best, bestLen = s.Name, len(d)
+				}
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bestBits := -1
+	for _, s := range c.services {
+		for _, p := range s.CIDRs {
+			if p.Bits() > bestBits && p.Contains(addr) {
+				best, bestBits = s.Name, p.Bits()
+			}
+		}
+	}
+	return best
+}
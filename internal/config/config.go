@@ -3,24 +3,89 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// DefaultRouterName — имя роутера, когда APP_ROUTERS не задан и используется
+// одиночная конфигурация APP_MIKROTIK_*.
+const DefaultRouterName = "default"
+
+type RouterConfig struct {
+	Name string
+
+	API  string // "api" (бинарный API, по умолчанию) или "rest" (REST API RouterOS 7)
+	Addr string // host:port, usually 8728 (8729 with TLS); для rest — host[:port] или https://host:port
+	User string
+	Pass string
+
+	// API-SSL
+	TLS            bool
+	TLSCAFile      string
+	TLSFingerprint string // SHA-256 сертификата роутера
+	TLSInsecure    bool
+
+	// IPv6: читать /ipv6/firewall/connection и DHCPv6, вести address-list в обоих семействах
+	IPv6 bool
+
+	// потоковый сбор (print follow-only) вместо полного print на каждом тике, только для api;
+	// StreamResync — период полной пересинхронизации зеркал
+	Stream       bool
+	StreamResync time.Duration
+}
+
 type Config struct {
 	HTTPPort string
 
 	SqliteDSN string
 
-	MikrotikAddr string // host:port, usually 8728
-	MikrotikUser string
-	MikrotikPass string
+	Routers []RouterConfig
 
 	IgnoreVPNListName      string
 	IgnoreLanToVpnListName string
+	// другие address-листы, которыми можно управлять через /api/v1/address-lists
+	AddressLists []string
+
+	// сопоставление доменов с ignore-VPN листом: exact, suffix или wildcard
+	IgnoreVPNMatch string
 
 	CollectInterval time.Duration
 
+	// хранение данных; 0 — без ограничения
+	HistoryRetention time.Duration
+	CountsRetention  time.Duration
+	DNSRetention     time.Duration
+	AuditRetention   time.Duration
+	// снимки address-листов; SnapshotInterval == 0 — без периодических снимков
+	SnapshotRetention time.Duration
+	SnapshotInterval  time.Duration
+
+	// запомненная связь адрес -> домен подставляется, пока запись истекла в кэше не раньше DNSMemory назад
+	DNSMemory time.Duration
+
+	// агрегаты рядов: минутные, часовые, дневные
+	RollupMinuteRetention time.Duration
+	RollupHourRetention   time.Duration
+	RollupDayRetention    time.Duration
+
+	PruneInterval time.Duration
+	PruneBatch    int
+
 	StaticDir string
+
+	// файл каталога сервисов (JSON), дополняет встроенный
+	ServicesFile string
+
+	// аутентификация API; первый администратор создаётся из AdminUser/AdminPassword,
+	// если пользователей ещё нет
+	AuthDisabled  bool
+	AdminUser     string
+	AdminPassword string
+	SessionTTL    time.Duration
+	CookieSecure  bool
+
+	// источники, которым разрешены кросс-доменные запросы; пусто — только свой
+	CORSOrigins []string
 }
 
 func Load() Config {
@@ -53,20 +118,185 @@ func Load() Config {
 
 	dsn := os.Getenv("APP_SQLITE_DSN")
 
+	adminUser := os.Getenv("APP_ADMIN_USER")
+	if adminUser == "" {
+		adminUser = "admin"
+	}
+
 	return Config{
 		HTTPPort: port,
 
 		SqliteDSN: dsn,
 
-		MikrotikAddr: os.Getenv("APP_MIKROTIK_ADDR"),
-		MikrotikUser: os.Getenv("APP_MIKROTIK_USER"),
-		MikrotikPass: os.Getenv("APP_MIKROTIK_PASSWORD"),
+		Routers: loadRouters(),
 
 		IgnoreVPNListName:      ignoreList,
 		IgnoreLanToVpnListName: ignoreLanToVpn,
+		AddressLists:           splitCSV(os.Getenv("APP_ADDRESS_LISTS")),
+
+		IgnoreVPNMatch: os.Getenv("APP_IGNORE_VPN_MATCH"),
 
 		CollectInterval: interval,
 
+		HistoryRetention:  envDays("APP_RETENTION_HISTORY_DAYS", 30),
+		CountsRetention:   envDays("APP_RETENTION_COUNTS_DAYS", 7),
+		DNSRetention:      envDays("APP_RETENTION_DNS_DAYS", 30),
+		AuditRetention:    envDays("APP_RETENTION_AUDIT_DAYS", 365),
+		SnapshotRetention: envDays("APP_RETENTION_SNAPSHOT_DAYS", 90),
+		SnapshotInterval:  envMinutes("APP_SNAPSHOT_MINUTES", 60),
+
+		DNSMemory: envHours("APP_DNS_MEMORY_HOURS", 24),
+
+		RollupMinuteRetention: envDays("APP_RETENTION_ROLLUP_MINUTE_DAYS", 2),
+		RollupHourRetention:   envDays("APP_RETENTION_ROLLUP_HOUR_DAYS", 90),
+		RollupDayRetention:    envDays("APP_RETENTION_ROLLUP_DAY_DAYS", 730),
+
+		PruneInterval: time.Duration(envInt("APP_PRUNE_MINUTES", 60)) * time.Minute,
+		PruneBatch:    envInt("APP_PRUNE_BATCH", 500),
+
 		StaticDir: staticDir,
+
+		ServicesFile: os.Getenv("APP_SERVICES_FILE"),
+
+		AuthDisabled:  parseBool(os.Getenv("APP_AUTH_DISABLED")),
+		AdminUser:     adminUser,
+		AdminPassword: os.Getenv("APP_ADMIN_PASSWORD"),
+		SessionTTL:    envHours("APP_SESSION_HOURS", 7*24),
+		CookieSecure:  parseBool(os.Getenv("APP_COOKIE_SECURE")),
+
+		CORSOrigins: splitCSV(os.Getenv("APP_CORS_ORIGINS")),
+	}
+}
+
+// envInt: положительное целое из окружения или def.
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// envHours: число часов из окружения; "0" — выключено.
+func envHours(key string, def int) time.Duration {
+	hours := def
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			hours = n
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// envMinutes: число минут из окружения; "0" — выключено.
+func envMinutes(key string, def int) time.Duration {
+	minutes := def
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			minutes = n
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// envDays: число дней из окружения; "0" — без ограничения.
+func envDays(key string, def int) time.Duration {
+	days := def
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// loadRouters читает список роутеров.
+//
+// APP_ROUTERS=main,branch1 — имена роутеров; для каждого читаются
+// APP_ROUTER_<NAME>_ADDR, APP_ROUTER_<NAME>_USER, APP_ROUTER_<NAME>_PASSWORD
+// (NAME в верхнем регистре, не буквенно-цифровые символы заменяются на "_").
+// Остальные ключи (API, USER, PASSWORD, TLS, TLS_CA, TLS_FINGERPRINT, TLS_INSECURE,
+// IPV6, STREAM, STREAM_RESYNC_MINUTES),
+// если не заданы для роутера, берутся из общих APP_MIKROTIK_*.
+//
+// Без APP_ROUTERS работает как раньше: один роутер DefaultRouterName из APP_MIKROTIK_*.
+func loadRouters() []RouterConfig {
+	names := splitCSV(os.Getenv("APP_ROUTERS"))
+	if len(names) == 0 {
+		rc := loadRouter(DefaultRouterName)
+		rc.Addr = routerEnv(DefaultRouterName, "ADDR", os.Getenv("APP_MIKROTIK_ADDR"))
+		return []RouterConfig{rc}
+	}
+
+	seen := map[string]bool{}
+	out := make([]RouterConfig, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		out = append(out, loadRouter(name))
+	}
+	return out
+}
+
+func loadRouter(name string) RouterConfig {
+	return RouterConfig{
+		Name: name,
+		API:  strings.ToLower(routerEnv(name, "API", os.Getenv("APP_MIKROTIK_API"))),
+		Addr: routerEnv(name, "ADDR", ""),
+		User: routerEnv(name, "USER", os.Getenv("APP_MIKROTIK_USER")),
+		Pass: routerEnv(name, "PASSWORD", os.Getenv("APP_MIKROTIK_PASSWORD")),
+
+		TLS:            parseBool(routerEnv(name, "TLS", os.Getenv("APP_MIKROTIK_TLS"))),
+		TLSCAFile:      routerEnv(name, "TLS_CA", os.Getenv("APP_MIKROTIK_TLS_CA")),
+		TLSFingerprint: routerEnv(name, "TLS_FINGERPRINT", os.Getenv("APP_MIKROTIK_TLS_FINGERPRINT")),
+		TLSInsecure:    parseBool(routerEnv(name, "TLS_INSECURE", os.Getenv("APP_MIKROTIK_TLS_INSECURE"))),
+
+		IPv6: parseBool(routerEnv(name, "IPV6", os.Getenv("APP_MIKROTIK_IPV6"))),
+
+		Stream:       parseBool(routerEnv(name, "STREAM", os.Getenv("APP_MIKROTIK_STREAM"))),
+		StreamResync: time.Duration(envInt("APP_ROUTER_"+envName(name)+"_STREAM_RESYNC_MINUTES", envInt("APP_MIKROTIK_STREAM_RESYNC_MINUTES", 5))) * time.Minute,
+	}
+}
+
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+func routerEnv(name, key, fallback string) string {
+	if v := os.Getenv("APP_ROUTER_" + envName(name) + "_" + key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func splitCSV(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
 	}
+	return out
 }
@@ -1,27 +1,84 @@
 package domain
 
+import "time"
+
 type Connection struct {
+	ID        string `json:"id,omitempty"` // семейство и .id записи conntrack на роутере, например "ip*1A"
+	Router    string `json:"router,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
 	SrcIP     string `json:"srcIP"`
+	SrcPort   string `json:"srcPort,omitempty"`
 	DstIP     string `json:"dstIP"`
+	DstPort   string `json:"dstPort,omitempty"`
 	DstDNS    string `json:"dstDNS"`
 	HostName  string `json:"hostName"`
 	CreatedAt string `json:"createdAt,omitempty"`
+
+	DstCNAMEs     []string `json:"dstCnames,omitempty"`     // CNAME от DstDNS до имени A/AAAA-записи
+	DstOtherNames []string `json:"dstOtherNames,omitempty"` // другие запрошенные имена с тем же адресом
+	DstDNSSource  string   `json:"dstDnsSource,omitempty"`  // cache — из DNS-кэша роутера, memory — запомненная связь
+
+	// счётчики conntrack с момента открытия соединения: orig — от клиента, repl — к клиенту
+	OrigBytes      int64  `json:"origBytes,omitempty"`
+	ReplBytes      int64  `json:"replBytes,omitempty"`
+	OrigPackets    int64  `json:"origPackets,omitempty"`
+	ReplPackets    int64  `json:"replPackets,omitempty"`
+	TCPState       string `json:"tcpState,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+	ConnectionMark string `json:"connectionMark,omitempty"`
+}
+
+// Session — соединение из истории: один 5-tuple от первого до последнего тика, в котором он был виден.
+type Session struct {
+	Router    string `json:"router"`
+	Protocol  string `json:"protocol"`
+	SrcIP     string `json:"srcIP"`
+	SrcPort   string `json:"srcPort"`
+	DstIP     string `json:"dstIP"`
+	DstPort   string `json:"dstPort"`
+	DstDNS    string `json:"dstDNS"`
+	HostName  string `json:"hostName"`
+	FirstSeen string `json:"firstSeen"`
+	LastSeen  string `json:"lastSeen"`
+	Open      bool   `json:"open"`
+	OrigBytes int64  `json:"origBytes"` // последние увиденные счётчики
+	ReplBytes int64  `json:"replBytes"`
 }
 
 type DnsConnection struct {
-	DstIP       string `json:"dstIP"`
-	DstDNS      string `json:"dstDNS"`
-	IsIgnoreVPN bool   `json:"isIgnoreVpn"`
+	DstIP       string   `json:"dstIP"`
+	DstDNS      string   `json:"dstDNS"`
+	CNAMEs      []string `json:"cnames,omitempty"`
+	IsIgnoreVPN bool     `json:"isIgnoreVpn"`
 }
 
 type GroupedDnsConnection struct {
-	DstDNS      string          `json:"dstDns"`
-	Items       []DnsConnection `json:"items"`
-	IsIgnoreVPN bool            `json:"isIgnoreVpn"`
+	Router         string          `json:"router,omitempty"`
+	DstDNS         string          `json:"dstDns"`
+	Items          []DnsConnection `json:"items"`
+	IsIgnoreVPN    bool            `json:"isIgnoreVpn"`
+	IgnoreVPNEntry string          `json:"ignoreVpnEntry,omitempty"` // запись листа, давшая совпадение
+
+	IgnoreVPNUpdatedAt time.Time `json:"ignoreVpnUpdatedAt"` // когда лист был прочитан с роутера
 }
 
+// HostConnection — LAN-клиент: аренда DHCP и/или src-адрес, замеченный в соединениях.
 type HostConnection struct {
-	SrcIP       string       `json:"srcIP"`
-	HostName    string       `json:"hostName"`
-	Connections []Connection `json:"connections"`
+	Router            string       `json:"router,omitempty"`
+	SrcIP             string       `json:"srcIP"`
+	HostName          string       `json:"hostName"`
+	MAC               string       `json:"mac,omitempty"`
+	ActiveConnections int64        `json:"activeConnections"`
+	UpBytes           int64        `json:"upBytes"`   // за окно запроса
+	DownBytes         int64        `json:"downBytes"` // за окно запроса
+	TopDomains        []HostDomain `json:"topDomains"`
+	IsIgnoreLanToVpn  bool         `json:"isIgnoreLanToVpn"`
+	Connections       []Connection `json:"connections,omitempty"`
+}
+
+// HostDomain — домен, с которым общался клиент: число сессий и их байты за окно.
+type HostDomain struct {
+	DstDNS   string `json:"dstDns"`
+	Sessions int64  `json:"sessions"`
+	Bytes    int64  `json:"bytes"`
 }
@@ -0,0 +1,175 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getAddressLists — имена листов, которыми можно управлять.
+func (h *Handler) getAddressLists(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, rt := range routers {
+		for _, name := range rt.Connections.ManagedLists() {
+			if !seen[name] {
+				seen[name] = true
+				res = append(res, name)
+			}
+		}
+	}
+	writeJSON(w, 200, res)
+}
+
+func (h *Handler) getAddressList(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	f := service.ListFilter{
+		Find:     q.Get("find"),
+		State:    strings.TrimSpace(q.Get("state")),
+		Resolved: q.Get("resolved") == "true",
+	}
+	if f.State != "" && f.State != storage.StateEnabled && f.State != storage.StateDisabled {
+		writeJSON(w, 400, map[string]any{"error": "state: must be enabled or disabled"})
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	res := []service.AddressListEntry{}
+	for _, rt := range routers {
+		items, err := rt.Connections.AddressListEntries(r.Context(), name, f)
+		if err != nil {
+			writeListError(w, rt.Name, err)
+			return
+		}
+		res = append(res, items...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Address < res[j].Address })
+	writeJSON(w, 200, res)
+}
+
+type addressListReq struct {
+	Addresses []string `json:"addresses"`
+	Enabled   *bool    `json:"enabled"`           // по умолчанию true
+	Comment   *string  `json:"comment,omitempty"` // null — не менять, "" — очистить
+	Timeout   string   `json:"timeout,omitempty"` // "2h" — временные записи
+}
+
+// postAddressList добавляет/включает или выключает записи: JSON {addresses, enabled, comment, timeout}.
+func (h *Handler) postAddressList(w http.ResponseWriter, r *http.Request) {
+	var req addressListReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+	opts, err := parseEntryOptions(req.Comment, req.Timeout, enabled)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	op := service.OpDisable
+	if enabled {
+		op = service.OpEnable
+	}
+	h.changeAddressList(w, r, service.ChangesFor(req.Addresses, op, opts))
+}
+
+// deleteAddressList удаляет записи: ?address=a,b.
+func (h *Handler) deleteAddressList(w http.ResponseWriter, r *http.Request) {
+	addresses := mikrotik.SplitDomainsCSV(r.URL.Query().Get("address"))
+	h.changeAddressList(w, r, service.ChangesFor(addresses, service.OpRemove, mikrotik.EntryOptions{}))
+}
+
+type bulkChangeReq struct {
+	Address string  `json:"address"`
+	Op      string  `json:"op"`                // enable | disable | remove
+	Comment *string `json:"comment,omitempty"` // null — не менять, "" — очистить
+	Timeout string  `json:"timeout,omitempty"`
+}
+
+// postAddressListBulk применяет разные операции к нескольким записям одним снимком:
+// JSON {"changes": [{address, op, comment, timeout}]}.
+func (h *Handler) postAddressListBulk(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Changes []bulkChangeReq `json:"changes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+
+	changes := make([]service.EntryChange, 0, len(req.Changes))
+	for _, c := range req.Changes {
+		opts, err := parseEntryOptions(c.Comment, c.Timeout, c.Op == service.OpEnable)
+		if err != nil {
+			writeJSON(w, 400, map[string]any{"error": c.Address + ": " + err.Error()})
+			return
+		}
+		changes = append(changes, service.EntryChange{Address: c.Address, Op: c.Op, Opts: opts})
+	}
+	h.changeAddressList(w, r, changes)
+}
+
+func (h *Handler) changeAddressList(w http.ResponseWriter, r *http.Request, changes []service.EntryChange) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+	if len(changes) == 0 {
+		writeJSON(w, 400, map[string]any{"error": "no addresses"})
+		return
+	}
+
+	// результат по каждой записи — и при ошибке: часть записей могла измениться
+	name := chi.URLParam(r, "name")
+	results := []service.EntryResult{}
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		items, err := rt.Connections.ChangeList(r.Context(), name, changes)
+		results = append(results, items...)
+		return err
+	})
+	writeRouterResults(w, res, err, map[string]any{"results": results})
+}
+
+// writeListError отвечает ошибкой чтения листа; код — по listErrorStatus.
+func writeListError(w http.ResponseWriter, router string, err error) {
+	if code := listErrorStatus(err); code != 500 {
+		writeJSON(w, code, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 500, map[string]any{"error": router + ": " + err.Error()})
+}
+
+// listErrorStatus: лист не из allow-list — 404, неверное изменение — 400, остальное — 500.
+func listErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUnknownList):
+		return 404
+	case errors.Is(err, service.ErrBadChange):
+		return 400
+	default:
+		return 500
+	}
+}
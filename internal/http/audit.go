@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// requestOrigin кладёт в контекст адрес клиента и User-Agent для журнала изменений.
+func (h *Handler) requestOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		ctx := service.WithOrigin(r.Context(), service.RequestOrigin{Addr: addr, UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (h *Handler) getAudit(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	f := storage.AuditFilter{
+		Actor:   strings.TrimSpace(q.Get("actor")),
+		List:    strings.TrimSpace(q.Get("list")),
+		Address: strings.TrimSpace(q.Get("address")),
+		Result:  strings.TrimSpace(q.Get("result")),
+	}
+	if f.Result != "" && f.Result != "ok" && f.Result != "error" {
+		writeJSON(w, 400, map[string]any{"error": "result: must be ok or error"})
+		return
+	}
+	if f.From, err = parseTimeParam(q.Get("from")); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "from: " + err.Error()})
+		return
+	}
+	if f.To, err = parseTimeParam(q.Get("to")); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "to: " + err.Error()})
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			writeJSON(w, 400, map[string]any{"error": "limit: must be a non-negative integer"})
+			return
+		}
+	}
+	csvExport := q.Get("format") == "csv"
+	if csvExport && f.Limit == 0 {
+		f.Limit = -1 // выгрузка без limit — весь журнал, а не последние 1000 записей
+	}
+
+	res := []storage.AuditEntry{}
+	for _, rt := range routers {
+		items, err := rt.Connections.Audit(r.Context(), f)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	// id сквозной по всем роутерам — общий порядок «новые сверху»
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	if f.Limit > 0 && len(res) > f.Limit {
+		res = res[:f.Limit]
+	}
+
+	if csvExport {
+		writeAuditCSV(w, res)
+		return
+	}
+	writeJSON(w, 200, res)
+}
+
+func writeAuditCSV(w http.ResponseWriter, items []storage.AuditEntry) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+	w.WriteHeader(200)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "at", "router", "actor", "via", "origin", "userAgent", "list", "family",
+		"address", "before", "after", "comment", "timeout", "result", "error"})
+	for _, e := range items {
+		row := []string{e.At, e.Router, e.Actor, e.Via, e.Origin, e.UserAgent,
+			e.List, e.Family, e.Address, e.Before, e.After, e.Comment, e.Timeout, e.Result, e.Error}
+		for i, v := range row {
+			row[i] = csvCell(v)
+		}
+		_ = cw.Write(append([]string{strconv.FormatInt(e.ID, 10)}, row...))
+	}
+	cw.Flush()
+}
+
+// csvCell экранирует значение, которое табличный редактор принял бы за формулу
+// (комментарий, User-Agent и адрес задаёт клиент): в начало добавляется апостроф.
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
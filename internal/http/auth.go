@@ -0,0 +1,350 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+)
+
+// sessionCookie — cookie сессии SPA.
+const sessionCookie = "mp_session"
+
+// UseCORS разрешает кросс-доменные запросы с origins ("*" — с любых, но тогда без cookie).
+// Без вызова CORS-заголовков нет: SPA отдаётся этим же сервером.
+func (h *Handler) UseCORS(origins []string) { h.corsOrigins = origins }
+
+// UseSecureCookie ставит cookie сессии с флагом Secure (за HTTPS-прокси).
+func (h *Handler) UseSecureCookie(secure bool) { h.secureCookie = secure }
+
+func (h *Handler) corsMiddleware() func(http.Handler) http.Handler {
+	if len(h.corsOrigins) == 0 {
+		return nil
+	}
+	any := slices.Contains(h.corsOrigins, "*")
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   h.corsOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowCredentials: !any, // cookie — только для явно перечисленных источников
+		MaxAge:           300,
+	})
+}
+
+// originAllowed: запрос без Origin, с того же хоста или из разрешённых CORS-источников.
+func (h *Handler) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return slices.Contains(h.corsOrigins, origin) || slices.Contains(h.corsOrigins, "*")
+}
+
+// authenticate определяет автора запроса по токену (Authorization: Bearer) или cookie сессии.
+// Без AuthService (аутентификация выключена) пропускает всех.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var (
+			p   service.Principal
+			err = service.ErrUnauthorized
+		)
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			p, err = h.auth.Token(r.Context(), strings.TrimSpace(token))
+		} else if c, cerr := r.Cookie(sessionCookie); cerr == nil {
+			p, err = h.auth.Session(r.Context(), c.Value)
+			// cookie браузер шлёт сам: изменяющие запросы — только со своих страниц
+			if err == nil && r.Method != http.MethodGet && r.Method != http.MethodHead && !h.originAllowed(r) {
+				writeJSON(w, 403, map[string]any{"error": "cross-origin request"})
+				return
+			}
+		}
+		if errors.Is(err, service.ErrUnauthorized) {
+			writeJSON(w, 401, map[string]any{"error": "unauthorized"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// requireRole пропускает запросы с ролью не ниже role.
+func (h *Handler) requireRole(role service.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			p, ok := service.PrincipalFrom(r.Context())
+			if !ok || !p.Role.Allows(role) {
+				writeJSON(w, 403, map[string]any{"error": "requires role " + string(role)})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type loginReq struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func (h *Handler) postLogin(w http.ResponseWriter, r *http.Request) {
+	if h.auth == nil {
+		writeJSON(w, 404, map[string]any{"error": "authentication is disabled"})
+		return
+	}
+	var req loginReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+
+	id, p, err := h.auth.Login(r.Context(), req.Name, req.Password)
+	if errors.Is(err, service.ErrBadCredentials) {
+		writeJSON(w, 401, map[string]any{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(h.auth.SessionTTL() / time.Second),
+		HttpOnly: true,
+		Secure:   h.secureCookie || r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeJSON(w, 200, p)
+}
+
+func (h *Handler) postLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil && h.auth != nil {
+		if err := h.auth.Logout(r.Context(), c.Value); err != nil {
+			writeJSON(w, 500, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
+
+func (h *Handler) getMe(w http.ResponseWriter, r *http.Request) {
+	p, ok := service.PrincipalFrom(r.Context())
+	if !ok {
+		// аутентификация выключена — доступно всё
+		writeJSON(w, 200, map[string]any{"role": service.RoleAdmin, "authDisabled": true})
+		return
+	}
+	writeJSON(w, 200, p)
+}
+
+type tokenReq struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`     // по умолчанию — роль владельца
+	TTLHours int    `json:"ttlHours"` // 0 — бессрочный
+}
+
+func (h *Handler) getTokens(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.auth.Tokens(r.Context(), p)
+	if err != nil {
+		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 200, items)
+}
+
+func (h *Handler) postToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	var req tokenReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+	role := p.Role
+	if req.Role != "" {
+		var err error
+		if role, err = service.ParseRole(req.Role); err != nil {
+			writeJSON(w, 400, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+	if strings.TrimSpace(req.Name) == "" || req.TTLHours < 0 {
+		writeJSON(w, 400, map[string]any{"error": "name is required, ttlHours must be >= 0"})
+		return
+	}
+
+	token, t, err := h.auth.CreateToken(r.Context(), p, req.Name, role, time.Duration(req.TTLHours)*time.Hour)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+	// значение токена показывается только один раз
+	writeJSON(w, 200, map[string]any{"token": token, "info": t})
+}
+
+func (h *Handler) deleteToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad id"})
+		return
+	}
+	if err := h.auth.DeleteToken(r.Context(), p, id); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
+
+type userReq struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func (h *Handler) getUsers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.principal(w, r); !ok {
+		return
+	}
+	users, err := h.auth.Users(r.Context())
+	if err != nil {
+		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 200, users)
+}
+
+func (h *Handler) postUser(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.principal(w, r); !ok {
+		return
+	}
+	var req userReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+	role, err := service.ParseRole(req.Role)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+	u, err := h.auth.CreateUser(r.Context(), req.Name, req.Password, role)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 200, u)
+}
+
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.principal(w, r); !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad id"})
+		return
+	}
+	var req userReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad json"})
+		return
+	}
+	// пустая роль — оставить прежнюю (например, только смена пароля)
+	var role service.Role
+	if strings.TrimSpace(req.Role) != "" {
+		if role, err = service.ParseRole(req.Role); err != nil {
+			writeJSON(w, 400, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+	if role == "" && req.Password == "" {
+		writeJSON(w, 400, map[string]any{"error": "password or role is required"})
+		return
+	}
+	if err := h.auth.UpdateUser(r.Context(), id, req.Password, role); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad id"})
+		return
+	}
+	if id == p.UserID {
+		writeJSON(w, 400, map[string]any{"error": "cannot delete yourself"})
+		return
+	}
+	if err := h.auth.DeleteUser(r.Context(), id); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
+
+// principal — автор запроса для ручек пользователей и токенов (они есть только при включённой аутентификации).
+func (h *Handler) principal(w http.ResponseWriter, r *http.Request) (service.Principal, bool) {
+	p, ok := service.PrincipalFrom(r.Context())
+	if h.auth == nil || !ok {
+		writeJSON(w, 404, map[string]any{"error": "authentication is disabled"})
+		return service.Principal{}, false
+	}
+	return p, true
+}
+
+func writeStorageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, 404, map[string]any{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrLastAdmin) {
+		writeJSON(w, 409, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 500, map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,93 @@
+package httpapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"mikrotik-parser-go/internal/service"
+)
+
+// login открывает сессию и возвращает cookie.
+func login(t *testing.T, base, name, password string) *http.Cookie {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"name": name, "password": password})
+	resp, err := http.Post(base+"/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	for _, c := range resp.Cookies() {
+		if c.Name == "mp_session" {
+			return c
+		}
+	}
+	t.Fatalf("login %s: status %d, no session cookie", name, resp.StatusCode)
+	return nil
+}
+
+func TestAuthorization(t *testing.T) {
+	ctx := context.Background()
+	_, base, auth := startAPIWithAuth(t, true)
+	u, _ := url.Parse(base)
+	self := u.Scheme + "://" + u.Host
+
+	users := map[service.Role]service.Principal{}
+	for _, role := range []service.Role{service.RoleViewer, service.RoleOperator} {
+		usr, err := auth.CreateUser(ctx, string(role), "password", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[role] = service.Principal{UserID: usr.ID, Name: usr.Name, Role: role}
+	}
+	viewer := login(t, base, "viewer", "password")
+	operator := login(t, base, "operator", "password")
+	token, _, err := auth.CreateToken(ctx, users[service.RoleOperator], "ci", service.RoleOperator, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const toggle = "/dns?dns=example.com&enabled=true"
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		cookie *http.Cookie
+		bearer string
+		origin string
+		want   int
+	}{
+		{"no credentials", "GET", "/dns", nil, "", "", 401},
+		{"bad token", "GET", "/dns", nil, "mpt_nope", "", 401},
+		{"viewer reads", "GET", "/dns", viewer, "", "", 200},
+		{"viewer writes", "POST", toggle, viewer, "", "", 403},
+		{"operator writes", "POST", toggle, operator, "", "", 200},
+		{"operator from own origin", "POST", toggle, operator, "", self, 200},
+		{"operator cookie from foreign origin", "POST", toggle, operator, "", "https://evil.example", 403},
+		{"foreign origin may read", "GET", "/dns", operator, "", "https://evil.example", 200},
+		{"token from foreign origin", "POST", toggle, nil, token, "https://evil.example", 200},
+		{"operator manages users", "GET", "/auth/users", operator, "", "", 403},
+	} {
+		req, _ := http.NewRequest(tc.method, base+tc.path, nil)
+		if tc.cookie != nil {
+			req.AddCookie(tc.cookie)
+		}
+		if tc.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+		}
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: %s %s -> %d, want %d", tc.name, tc.method, tc.path, resp.StatusCode, tc.want)
+		}
+	}
+}
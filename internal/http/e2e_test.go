@@ -0,0 +1,263 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	httpapi "mikrotik-parser-go/internal/http"
+	imigrate "mikrotik-parser-go/internal/migrate"
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/mikrotik/fake"
+	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
+)
+
+const (
+	testRouter  = "r1"
+	ignoreVPN   = "ignoreVpn"
+	addressList = "/ip/firewall/address-list"
+	connTable   = "/ip/firewall/connection"
+)
+
+// startAPI поднимает фейковый роутер, базу, коллектор и HTTP API (без аутентификации).
+func startAPI(t *testing.T) (*fake.Server, string) {
+	t.Helper()
+	rtr, base, _ := startAPIWithAuth(t, false)
+	return rtr, base
+}
+
+// startAPIWithAuth — то же; withAuth включает аутентификацию, AuthService возвращается для заведения пользователей.
+func startAPIWithAuth(t *testing.T, withAuth bool) (*fake.Server, string, *service.AuthService) {
+	t.Helper()
+
+	rtr := fake.New()
+	rtr.SetTable("/ip/dns/cache/all", []map[string]string{
+		{"name": "example.com", "type": "A", "data": "93.184.216.34", "ttl": "5m"},
+		{"name": "example.org", "type": "A", "data": "93.184.216.35", "ttl": "5m"},
+	})
+	rtr.SetTable("/ip/dhcp-server/lease", []map[string]string{
+		{"address": "192.168.88.10", "host-name": "laptop", "mac-address": "3C:22:FB:10:00:01"},
+	})
+	rtr.SetTable(connTable, []map[string]string{
+		{"protocol": "tcp", "src-address": "192.168.88.10:50000", "dst-address": "93.184.216.34:443", "orig-bytes": "100", "repl-bytes": "1000"},
+	})
+	rtr.SetTable(addressList, nil)
+	if err := rtr.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rtr.Close() })
+
+	dsn := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err := imigrate.Up(dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := storage.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	mt := mikrotik.New(rtr.Addr(), "admin", "")
+	t.Cleanup(func() { _ = mt.Close() })
+
+	conns := service.NewConnectionsService(testRouter, mt, ignoreVPN, "ignoreLanToVpn", false)
+	conns.UseAudit(db)
+	collect := service.NewCollectService(conns, db, 50*time.Millisecond)
+	broker := service.NewBroker()
+	collect.UseBroker(broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go collect.Run(ctx)
+
+	var auth *service.AuthService
+	if withAuth {
+		auth = service.NewAuthService(db, time.Hour)
+	}
+	routers := []*service.Router{{Name: testRouter, Client: mt, Connections: conns, Collect: collect}}
+	srv := httptest.NewServer(httpapi.NewHandler(routers, nil, broker, auth, "").Router())
+	t.Cleanup(func() {
+		broker.Close()
+		srv.Close()
+		cancel()
+	})
+	return rtr, srv.URL + "/api/v1", auth
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+}
+
+func post(t *testing.T, url string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != 200 || body["ok"] != true {
+		t.Fatalf("POST %s: status %d, body %v", url, resp.StatusCode, body)
+	}
+}
+
+// eventually повторяет check, пока он не вернёт true, но не дольше 5 секунд.
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// byDNS — строка ответа GET /dns для домена dns (nil — нет).
+func byDNS(t *testing.T, base, dns string) map[string]any {
+	var rows []map[string]any
+	getJSON(t, base+"/dns?find="+dns, &rows)
+	for _, r := range rows {
+		if r["dstDns"] == dns {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestCollectAndQuery(t *testing.T) {
+	_, base := startAPI(t)
+
+	// тик коллектора записывает счётчики доменов — по ним отвечает /dns
+	var row map[string]any
+	eventually(t, "collector tick", func() bool {
+		row = byDNS(t, base, "example.com")
+		return row != nil
+	})
+	if row["activeConnections"] != 1.0 || row["router"] != testRouter {
+		t.Fatalf("/dns row = %v", row)
+	}
+
+	var groups []map[string]any
+	getJSON(t, base+"/src?srcIp=192.168.88.10", &groups)
+	if len(groups) != 1 || groups[0]["dstDns"] != "example.com" {
+		t.Fatalf("/src = %v", groups)
+	}
+	getJSON(t, base+"/src?srcIp=192.168.88.1", &groups)
+	if len(groups) != 0 {
+		t.Fatalf("/src for another host = %v", groups)
+	}
+}
+
+func TestIgnoreListToggle(t *testing.T) {
+	rtr, base := startAPI(t)
+	eventually(t, "collector tick", func() bool { return byDNS(t, base, "example.com") != nil })
+
+	entry := func() map[string]string {
+		for _, r := range rtr.Table(addressList) {
+			if r["list"] == ignoreVPN && r["address"] == "example.com" {
+				return r
+			}
+		}
+		return nil
+	}
+
+	post(t, base+"/dns?dns=example.com&enabled=true&comment=test")
+	if e := entry(); e == nil || e["disabled"] != "false" || e["comment"] != "test" {
+		t.Fatalf("entry after enable = %v", e)
+	}
+	if row := byDNS(t, base, "example.com"); row["isIgnoreVpn"] != true {
+		t.Fatalf("/dns after enable = %v", row)
+	}
+
+	post(t, base+"/dns?dns=example.com&enabled=false")
+	if e := entry(); e == nil || e["disabled"] != "true" {
+		t.Fatalf("entry after disable = %v", e)
+	}
+	if row := byDNS(t, base, "example.com"); row["isIgnoreVpn"] != false {
+		t.Fatalf("/dns after disable = %v", row)
+	}
+
+	var audit []storage.AuditEntry
+	getJSON(t, base+"/audit?list="+ignoreVPN, &audit)
+	if len(audit) != 2 || audit[0].After != storage.StateDisabled || audit[1].After != storage.StateEnabled {
+		t.Fatalf("audit = %+v", audit)
+	}
+}
+
+func TestStream(t *testing.T) {
+	rtr, base := startAPI(t)
+	// подписка после первого тика начинается со snapshot
+	eventually(t, "collector tick", func() bool { return byDNS(t, base, "example.com") != nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, base+"/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("content type %q", ct)
+	}
+
+	events := bufio.NewScanner(resp.Body)
+	next := func() (string, service.StreamUpdate) {
+		t.Helper()
+		var typ string
+		for events.Scan() {
+			line := events.Text()
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				typ = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				var u service.StreamUpdate
+				if err := json.Unmarshal([]byte(v), &u); err != nil {
+					t.Fatal(err)
+				}
+				return typ, u
+			}
+		}
+		t.Fatalf("stream ended: %v", events.Err())
+		return "", service.StreamUpdate{}
+	}
+
+	typ, u := next()
+	if typ != service.UpdateSnapshot || len(u.Opened) != 1 || u.Opened[0].DstDNS != "example.com" {
+		t.Fatalf("first event %s: %+v", typ, u)
+	}
+
+	rtr.Update(connTable, func(rows []map[string]string) []map[string]string {
+		return append(rows, map[string]string{
+			"protocol": "tcp", "src-address": "192.168.88.10:50001", "dst-address": "93.184.216.35:443",
+		})
+	})
+	for {
+		typ, u = next()
+		if typ == service.UpdateDelta && len(u.Opened) > 0 {
+			break
+		}
+	}
+	if len(u.Opened) != 1 || u.Opened[0].DstDNS != "example.org" || len(u.Closed) != 0 {
+		t.Fatalf("delta = %+v", u)
+	}
+}
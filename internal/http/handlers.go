@@ -2,24 +2,37 @@ package httpapi
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"mikrotik-parser-go/internal/domain"
+	"mikrotik-parser-go/internal/mikrotik"
 	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
-	connections *service.ConnectionsService
-	collect     *service.CollectService
-	staticDir   string
+	routers   []*service.Router
+	retention *service.RetentionService
+	broker    *service.Broker
+	staticDir string
+
+	// nil — аутентификация выключена
+	auth         *service.AuthService
+	corsOrigins  []string
+	secureCookie bool
 }
 
-func NewHandler(connections *service.ConnectionsService, collect *service.CollectService, staticDir string) *Handler {
-	return &Handler{connections: connections, collect: collect, staticDir: staticDir}
+func NewHandler(routers []*service.Router, retention *service.RetentionService, broker *service.Broker, auth *service.AuthService, staticDir string) *Handler {
+	return &Handler{routers: routers, retention: retention, broker: broker, auth: auth, staticDir: staticDir}
 }
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
@@ -30,14 +43,75 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 
 func (h *Handler) Router() http.Handler {
 	r := chi.NewRouter()
+	if mw := h.corsMiddleware(); mw != nil {
+		r.Use(mw)
+	}
 
 	// API
+	// все ручки принимают ?router=name1,name2 (по умолчанию — все роутеры)
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/src", h.getSrc)                            // ?srcIp=
-		r.Get("/dns", h.getByDNS)                          // ?find=
-		r.Post("/dns", h.postDNS)                          // ?dns=&enabled=
-		r.Get("/ignore-lan-to-vpn", h.getIgnoreLanToVpn)   // ?find=
-		r.Post("/ignore-lan-to-vpn", h.postIgnoreLanToVpn) // JSON {ip, enabled}
+		r.Post("/auth/login", h.postLogin) // JSON {name, password} -> cookie сессии
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.authenticate)
+			r.Use(h.requestOrigin)
+
+			// viewer: чтение
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireRole(service.RoleViewer))
+
+				r.Post("/auth/logout", h.postLogout)
+				r.Get("/auth/me", h.getMe)
+				r.Get("/auth/tokens", h.getTokens)           // свои токены (admin — все)
+				r.Post("/auth/tokens", h.postToken)          // JSON {name, role, ttlHours}
+				r.Delete("/auth/tokens/{id}", h.deleteToken) // свой токен (admin — любой)
+
+				r.Get("/routers", h.getRouters)
+				r.Get("/src", h.getSrc)                          // ?srcIp=
+				r.Get("/dns", h.getByDNS)                        // ?find=&group=domain
+				r.Get("/services", h.getServices)                // живой трафик по сервисам каталога
+				r.Get("/dns/series", h.getDNSSeries)             // ?domain=&ip=&window=&bucket=
+				r.Get("/dns/memory", h.getDNSMemory)             // ?ip=
+				r.Get("/history", h.getHistory)                  // ?from=&to=&srcIp=&dstIp=&domain=&limit=
+				r.Get("/traffic", h.getTraffic)                  // ?by=domain|dst|host&window=&limit=
+				r.Get("/hosts", h.getHosts)                      // ?window=&find=&sort=&order=&limit=&offset=&connections=
+				r.Get("/ignore-lan-to-vpn", h.getIgnoreLanToVpn) // ?find=
+				r.Get("/db/stats", h.getDBStats)
+				r.Get("/audit", h.getAudit)         // ?from=&to=&actor=&list=&address=&result=ok|error&limit=&format=csv
+				r.Get("/snapshots", h.getSnapshots) // ?list=&limit=
+				r.Get("/snapshots/{id}", h.getSnapshot)
+				r.Get("/snapshots/{id}/diff", h.getSnapshotDiff) // отличия снимка от живого листа
+				r.Get("/address-lists", h.getAddressLists)
+				r.Get("/address-lists/{name}", h.getAddressList) // ?find=&state=enabled|disabled&resolved=true
+				r.Get("/stream", h.getStream)                    // SSE; ?srcIp=&domain=
+				r.Get("/stream/ws", h.getStreamWS)               // WebSocket; ?srcIp=&domain=
+			})
+
+			// operator: переключение address-list на роутерах
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireRole(service.RoleOperator))
+
+				r.Post("/dns", h.postDNS)                              // ?dns=&service=&enabled=&comment=&timeout=
+				r.Delete("/dns", h.deleteDNS)                          // ?dns=&service=
+				r.Post("/ignore-lan-to-vpn", h.postIgnoreLanToVpn)     // JSON {ip, enabled, comment, timeout}
+				r.Delete("/ignore-lan-to-vpn", h.deleteIgnoreLanToVpn) // ?ip=
+				r.Post("/snapshots", h.postSnapshot)                   // ?list=
+				r.Post("/snapshots/{id}/restore", h.postSnapshotRestore)
+				r.Post("/address-lists/{name}", h.postAddressList)          // JSON {addresses, enabled, comment, timeout}
+				r.Delete("/address-lists/{name}", h.deleteAddressList)      // ?address=a,b
+				r.Post("/address-lists/{name}/bulk", h.postAddressListBulk) // JSON {changes: [{address, op, comment, timeout}]}
+			})
+
+			// admin: пользователи
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireRole(service.RoleAdmin))
+
+				r.Get("/auth/users", h.getUsers)
+				r.Post("/auth/users", h.postUser)        // JSON {name, password, role}
+				r.Patch("/auth/users/{id}", h.patchUser) // JSON {password, role}
+				r.Delete("/auth/users/{id}", h.deleteUser)
+			})
+		})
 	})
 
 	// Frontend (как в Spring: "/" -> index, + /js/**, /css/**, /favicon.ico)
@@ -77,54 +151,582 @@ func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, filepath.Join(h.staticDir, "index.html"))
 }
 
+// selectRouters разбирает ?router=: пусто или "all" — все роутеры, иначе имена через запятую.
+func (h *Handler) selectRouters(r *http.Request) ([]*service.Router, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("router"))
+	if v == "" || v == "all" {
+		return h.routers, nil
+	}
+
+	var out []*service.Router
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found *service.Router
+		for _, rt := range h.routers {
+			if rt.Name == name {
+				found = rt
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown router %q", name)
+		}
+		out = append(out, found)
+	}
+	return out, nil
+}
+
+// routerResult — итог записи на одном роутере.
+type routerResult struct {
+	Router string `json:"router"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// eachRouter выполняет запись fn на каждом роутере: ошибка одного не останавливает
+// остальные, иначе без ?router= роутеры расходились бы на первом сбое.
+// Возвращает итог по каждому роутеру и первую ошибку.
+func eachRouter(routers []*service.Router, fn func(rt *service.Router) error) ([]routerResult, error) {
+	res := make([]routerResult, 0, len(routers))
+	var first error
+	for _, rt := range routers {
+		err := fn(rt)
+		rr := routerResult{Router: rt.Name, OK: err == nil}
+		if err != nil {
+			rr.Error = err.Error()
+			if first == nil {
+				first = err
+			}
+		}
+		res = append(res, rr)
+	}
+	return res, first
+}
+
+// writeRouterResults отвечает итогами записи по роутерам: 200, если все справились, иначе
+// код первой ошибки (см. listErrorStatus) и "error" с ошибками всех роутеров.
+// body — дополнительные поля ответа.
+func writeRouterResults(w http.ResponseWriter, res []routerResult, first error, body map[string]any) {
+	if body == nil {
+		body = map[string]any{}
+	}
+	body["routers"] = res
+	if first == nil {
+		body["ok"] = true
+		writeJSON(w, 200, body)
+		return
+	}
+
+	var msgs []string
+	for _, rr := range res {
+		if !rr.OK {
+			msgs = append(msgs, rr.Router+": "+rr.Error)
+		}
+	}
+	body["ok"] = false
+	body["error"] = strings.Join(msgs, "; ")
+	writeJSON(w, listErrorStatus(first), body)
+}
+
+func (h *Handler) getRouters(w http.ResponseWriter, r *http.Request) {
+	out := make([]map[string]any, 0, len(h.routers))
+	for _, rt := range h.routers {
+		out = append(out, map[string]any{
+			"name":  rt.Name,
+			"state": rt.Client.State(),
+		})
+	}
+	writeJSON(w, 200, out)
+}
+
 func (h *Handler) getSrc(w http.ResponseWriter, r *http.Request) {
-	srcIP := r.URL.Query().Get("srcIp")
-	res, err := h.connections.GetBySrc(r.Context(), srcIP)
+	routers, err := h.selectRouters(r)
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
 		return
 	}
+
+	srcIP := r.URL.Query().Get("srcIp")
+	res := []domain.GroupedDnsConnection{}
+	for _, rt := range routers {
+		items, err := rt.Connections.GetBySrc(r.Context(), srcIP)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
 	writeJSON(w, 200, res)
 }
 
 func (h *Handler) getByDNS(w http.ResponseWriter, r *http.Request) {
-	find := r.URL.Query().Get("find")
-	res, err := h.collect.GetByDNS(r.Context(), find)
+	routers, err := h.selectRouters(r)
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	find := r.URL.Query().Get("find")
+	group := r.URL.Query().Get("group")
+	if group != "" && group != service.GroupByDomain {
+		writeJSON(w, 400, map[string]any{"error": "group: must be empty or " + service.GroupByDomain})
 		return
 	}
+
+	res := []map[string]any{}
+	for _, rt := range routers {
+		items, err := rt.Collect.GetByDNS(r.Context(), find, group)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	service.SortByActiveConnections(res)
 	writeJSON(w, 200, res)
 }
 
 func (h *Handler) postDNS(w http.ResponseWriter, r *http.Request) {
-	dns := r.URL.Query().Get("dns")
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
 	enabled := r.URL.Query().Get("enabled") == "true"
+	opts, err := parseEntryOptions(commentParam(r), r.URL.Query().Get("timeout"), enabled)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	dns := dnsParam(r)
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		return rt.Connections.PostDnsToIgnoreList(r.Context(), dns, enabled, opts)
+	})
+	writeRouterResults(w, res, err, nil)
+}
+
+// deleteDNS удаляет записи доменов из ignore-VPN листа (выключенные записи иначе копятся).
+func (h *Handler) deleteDNS(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	dns := dnsParam(r)
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		return rt.Connections.RemoveDnsFromIgnoreList(r.Context(), dns)
+	})
+	writeRouterResults(w, res, err, nil)
+}
+
+// dnsParam — ?dns= и ?service=Netflix,Telegram (сервисы каталога целиком) одним CSV.
+func dnsParam(r *http.Request) string {
+	dns := r.URL.Query().Get("dns")
+	for _, name := range mikrotik.SplitDomainsCSV(r.URL.Query().Get("service")) {
+		dns += "," + service.ServicePrefix + name
+	}
+	return dns
+}
+
+// commentParam — ?comment=: без параметра nil (комментарий не меняется), пустой — очистить.
+func commentParam(r *http.Request) *string {
+	if !r.URL.Query().Has("comment") {
+		return nil
+	}
+	v := r.URL.Query().Get("comment")
+	return &v
+}
+
+// parseEntryOptions: комментарий (nil — не менять, "" — очистить) и timeout записи ("2h", "30m");
+// timeout — только для включения и не меньше секунды.
+func parseEntryOptions(comment *string, timeout string, enabled bool) (mikrotik.EntryOptions, error) {
+	var opts mikrotik.EntryOptions
+	if comment != nil {
+		c := strings.TrimSpace(*comment)
+		opts.Comment = &c
+	}
+	if timeout = strings.TrimSpace(timeout); timeout == "" {
+		return opts, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d < time.Second {
+		return opts, fmt.Errorf("timeout: must be a duration of at least 1s (30m, 2h)")
+	}
+	if !enabled {
+		return opts, fmt.Errorf("timeout: only applies with enabled=true")
+	}
+	opts.Timeout = d
+	return opts, nil
+}
+
+func (h *Handler) getDNSSeries(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	dns := strings.TrimSpace(q.Get("domain"))
+	ip := strings.TrimSpace(q.Get("ip"))
+	if dns == "" && ip == "" {
+		writeJSON(w, 400, map[string]any{"error": "domain or ip is required"})
+		return
+	}
+
+	window := 24 * time.Hour
+	if v := q.Get("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+			writeJSON(w, 400, map[string]any{"error": "window: must be a positive duration (6h, 168h)"})
+			return
+		}
+	}
+
+	bucket := seriesBucket(window)
+	if v := q.Get("bucket"); v != "" {
+		if bucket, err = storage.ParseBucket(v); err != nil {
+			writeJSON(w, 400, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	res := []map[string]any{}
+	for _, rt := range routers {
+		points, err := rt.Collect.Series(r.Context(), bucket, dns, ip, from, to)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, map[string]any{
+			"router": rt.Name,
+			"points": points,
+		})
+	}
+	writeJSON(w, 200, map[string]any{
+		"domain": dns,
+		"ip":     ip,
+		"bucket": bucket,
+		"from":   from.UTC().Format(time.RFC3339),
+		"to":     to.UTC().Format(time.RFC3339),
+		"series": res,
+	})
+}
+
+func (h *Handler) getTraffic(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	by := q.Get("by")
+	switch by {
+	case "":
+		by = "domain"
+	case "domain", "dst", "host":
+	default:
+		writeJSON(w, 400, map[string]any{"error": "by: must be domain, dst or host"})
+		return
+	}
+
+	window := 24 * time.Hour
+	if v := q.Get("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+			writeJSON(w, 400, map[string]any{"error": "window: must be a positive duration (6h, 168h)"})
+			return
+		}
+	}
+
+	limit := 20
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeJSON(w, 400, map[string]any{"error": "limit: must be a positive integer"})
+			return
+		}
+	}
+
+	from := time.Now().Add(-window)
+	bucket := seriesBucket(window)
+	res := []storage.TrafficRank{}
+	for _, rt := range routers {
+		items, err := rt.Collect.TopTraffic(r.Context(), by, bucket, from, limit)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].OrigBytes+res[i].ReplBytes > res[j].OrigBytes+res[j].ReplBytes
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	writeJSON(w, 200, res)
+}
+
+func (h *Handler) getHosts(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	window := 24 * time.Hour
+	if v := q.Get("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+			writeJSON(w, 400, map[string]any{"error": "window: must be a positive duration (6h, 168h)"})
+			return
+		}
+	}
+
+	limit, offset := 100, 0
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeJSON(w, 400, map[string]any{"error": "limit: must be a positive integer"})
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeJSON(w, 400, map[string]any{"error": "offset: must be a non-negative integer"})
+			return
+		}
+	}
+
+	sortBy := q.Get("sort")
+	if sortBy == "" {
+		sortBy = "bytes"
+	}
+	// по умолчанию: числа — по убыванию, имя и адрес — по возрастанию
+	desc := sortBy != "name" && sortBy != "ip"
+	switch q.Get("order") {
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	}
+
+	find := strings.ToLower(strings.TrimSpace(q.Get("find")))
+	withConns := q.Get("connections") == "true"
+
+	from := time.Now().Add(-window)
+	bucket := seriesBucket(window)
+	res := []domain.HostConnection{}
+	for _, rt := range routers {
+		items, err := rt.Collect.Hosts(r.Context(), bucket, from, withConns)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		for _, it := range items {
+			if find != "" &&
+				!strings.Contains(it.SrcIP, find) &&
+				!strings.Contains(strings.ToLower(it.HostName), find) &&
+				!strings.Contains(strings.ToLower(it.MAC), find) {
+				continue
+			}
+			res = append(res, it)
+		}
+	}
+	if err := service.SortHosts(res, sortBy, desc); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "sort: " + err.Error()})
+		return
+	}
+
+	total := len(res)
+	res = res[min(offset, total):min(offset+limit, total)]
+	writeJSON(w, 200, map[string]any{
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"items":  res,
+	})
+}
+
+func (h *Handler) getDNSMemory(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if ip == "" {
+		writeJSON(w, 400, map[string]any{"error": "ip is required"})
+		return
+	}
+
+	res := []storage.DNSMapping{}
+	for _, rt := range routers {
+		items, err := rt.Collect.DNSMappings(r.Context(), ip)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].LastSeen > res[j].LastSeen })
+	writeJSON(w, 200, res)
+}
+
+// seriesBucket выбирает гранулярность так, чтобы точек было не больше нескольких сотен.
+func seriesBucket(window time.Duration) storage.Bucket {
+	switch {
+	case window <= 6*time.Hour:
+		return storage.BucketMinute
+	case window <= 14*24*time.Hour:
+		return storage.BucketHour
+	default:
+		return storage.BucketDay
+	}
+}
+
+func (h *Handler) getServices(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
 
-	if err := h.connections.PostDnsToIgnoreList(r.Context(), dns, enabled); err != nil {
+	res := []service.ServiceTraffic{}
+	for _, rt := range routers {
+		items, err := rt.Collect.ServiceTraffic(r.Context())
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	writeJSON(w, 200, res)
+}
+
+func (h *Handler) getHistory(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	q := r.URL.Query()
+	f := storage.HistoryFilter{
+		SrcIP:  strings.TrimSpace(q.Get("srcIp")),
+		DstIP:  strings.TrimSpace(q.Get("dstIp")),
+		Domain: strings.TrimSpace(q.Get("domain")),
+	}
+	if f.From, err = parseTimeParam(q.Get("from")); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "from: " + err.Error()})
+		return
+	}
+	if f.To, err = parseTimeParam(q.Get("to")); err != nil {
+		writeJSON(w, 400, map[string]any{"error": "to: " + err.Error()})
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			writeJSON(w, 400, map[string]any{"error": "limit: must be a non-negative integer"})
+			return
+		}
+	}
+
+	res := []domain.Session{}
+	for _, rt := range routers {
+		items, err := rt.Collect.History(r.Context(), f)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	// у каждого роутера свой limit — после склейки сортируем и обрезаем общий
+	sort.SliceStable(res, func(i, j int) bool { return res[i].LastSeen > res[j].LastSeen })
+	if f.Limit > 0 && len(res) > f.Limit {
+		res = res[:f.Limit]
+	}
+	writeJSON(w, 200, res)
+}
+
+// parseTimeParam: RFC3339 ("2024-05-01T10:00:00Z") или длительность назад от текущего момента ("2h", "30m").
+func parseTimeParam(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func (h *Handler) getDBStats(w http.ResponseWriter, r *http.Request) {
+	st, err := h.retention.Stats(r.Context())
+	if err != nil {
 		writeJSON(w, 500, map[string]any{"error": err.Error()})
 		return
 	}
-	writeJSON(w, 200, map[string]any{"ok": true})
+	p := h.retention.Policy()
+	writeJSON(w, 200, map[string]any{
+		"db": st,
+		"retention": map[string]any{
+			"historyDays":  int(p.History.Hours() / 24),
+			"countsDays":   int(p.Counts.Hours() / 24),
+			"dnsDays":      int(p.DNS.Hours() / 24),
+			"auditDays":    int(p.Audit.Hours() / 24),
+			"snapshotDays": int(p.Snapshots.Hours() / 24),
+			"rollupDays": map[storage.Bucket]int{
+				storage.BucketMinute: int(p.Rollups[storage.BucketMinute].Hours() / 24),
+				storage.BucketHour:   int(p.Rollups[storage.BucketHour].Hours() / 24),
+				storage.BucketDay:    int(p.Rollups[storage.BucketDay].Hours() / 24),
+			},
+		},
+	})
 }
 
 type ignoreLanToVpnReq struct {
-	IP       string `json:"ip"`
-	Enabled  bool   `json:"enabled"`
-	HostName string `json:"hostName,omitempty"`
+	IP       string  `json:"ip"`
+	Enabled  bool    `json:"enabled"`
+	HostName string  `json:"hostName,omitempty"`
+	Comment  *string `json:"comment,omitempty"` // null — не менять, "" — очистить
+	Timeout  string  `json:"timeout,omitempty"` // "2h" — временная запись
 }
 
 func (h *Handler) getIgnoreLanToVpn(w http.ResponseWriter, r *http.Request) {
-	find := r.URL.Query().Get("find")
-	items, err := h.connections.GetIgnoreLanToVpn(r.Context(), find)
+	routers, err := h.selectRouters(r)
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"error": err.Error()})
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
 		return
 	}
-	writeJSON(w, 200, items)
+
+	find := r.URL.Query().Get("find")
+	res := []service.IgnoreLanToVpnItem{}
+	for _, rt := range routers {
+		items, err := rt.Connections.GetIgnoreLanToVpn(r.Context(), find)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	writeJSON(w, 200, res)
 }
 
 func (h *Handler) postIgnoreLanToVpn(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
 	// поддержка и query, и JSON
 	ip := r.URL.Query().Get("ip")
 	enabledStr := r.URL.Query().Get("enabled")
@@ -143,9 +745,39 @@ func (h *Handler) postIgnoreLanToVpn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	enabled := enabledStr == "true" || enabledStr == "1"
-	if err := h.connections.PostIpToIgnoreLanToVpn(r.Context(), ip, enabled); err != nil {
-		writeJSON(w, 500, map[string]any{"error": err.Error()})
+	comment, timeout := req.Comment, req.Timeout
+	if v := commentParam(r); v != nil {
+		comment = v
+	}
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		timeout = v
+	}
+	opts, err := parseEntryOptions(comment, timeout, enabled)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		return rt.Connections.PostIpToIgnoreLanToVpn(r.Context(), ip, enabled, opts)
+	})
+	writeRouterResults(w, res, err, nil)
+}
+
+func (h *Handler) deleteIgnoreLanToVpn(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
 		return
 	}
-	writeJSON(w, 200, map[string]any{"ok": true})
+
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if ip == "" {
+		writeJSON(w, 400, map[string]any{"error": "ip is required"})
+		return
+	}
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		return rt.Connections.RemoveIpFromIgnoreLanToVpn(r.Context(), ip)
+	})
+	writeRouterResults(w, res, err, nil)
 }
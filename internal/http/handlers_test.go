@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"errors"
+	"testing"
+
+	"mikrotik-parser-go/internal/service"
+)
+
+func TestParseEntryOptions(t *testing.T) {
+	opts, err := parseEntryOptions(nil, "", true)
+	if err != nil || opts.Comment != nil {
+		t.Fatalf("no comment: opts=%+v err=%v, want nil comment", opts, err)
+	}
+
+	empty := " "
+	opts, err = parseEntryOptions(&empty, "", false)
+	if err != nil || opts.Comment == nil || *opts.Comment != "" {
+		t.Fatalf("empty comment: opts=%+v err=%v, want comment cleared", opts, err)
+	}
+
+	for _, timeout := range []string{"500ms", "0s", "-1m", "soon"} {
+		if _, err := parseEntryOptions(nil, timeout, true); err == nil {
+			t.Errorf("timeout %q accepted", timeout)
+		}
+	}
+	if _, err := parseEntryOptions(nil, "2h", false); err == nil {
+		t.Error("timeout accepted for disable")
+	}
+	if opts, err := parseEntryOptions(nil, "1s", true); err != nil || opts.Timeout.Seconds() != 1 {
+		t.Errorf("1s: opts=%+v err=%v", opts, err)
+	}
+}
+
+// Ошибка одного роутера не останавливает запись на остальных.
+func TestEachRouterContinuesAfterError(t *testing.T) {
+	routers := []*service.Router{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	var called []string
+	res, err := eachRouter(routers, func(rt *service.Router) error {
+		called = append(called, rt.Name)
+		if rt.Name == "a" {
+			return service.ErrBadChange
+		}
+		return nil
+	})
+	if !errors.Is(err, service.ErrBadChange) {
+		t.Fatalf("err = %v, want %v", err, service.ErrBadChange)
+	}
+	if len(called) != 3 {
+		t.Fatalf("called %v, want all routers", called)
+	}
+	if res[0].OK || !res[1].OK || !res[2].OK {
+		t.Fatalf("results = %+v", res)
+	}
+}
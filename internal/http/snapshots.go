@@ -0,0 +1,147 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mikrotik-parser-go/internal/service"
+	"mikrotik-parser-go/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) getSnapshots(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	list := strings.TrimSpace(r.URL.Query().Get("list"))
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			writeJSON(w, 400, map[string]any{"error": "limit: must be a non-negative integer"})
+			return
+		}
+	}
+
+	res := []storage.ListSnapshot{}
+	for _, rt := range routers {
+		items, err := rt.Connections.Snapshots(r.Context(), list, limit)
+		if err != nil {
+			writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+			return
+		}
+		res = append(res, items...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	writeJSON(w, 200, res)
+}
+
+// postSnapshot снимает листы сейчас: ?list= (по умолчанию — все управляемые).
+func (h *Handler) postSnapshot(w http.ResponseWriter, r *http.Request) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return
+	}
+
+	res := []storage.ListSnapshot{}
+	results, err := eachRouter(routers, func(rt *service.Router) error {
+		lists := rt.Connections.ManagedLists()
+		if v := strings.TrimSpace(r.URL.Query().Get("list")); v != "" {
+			lists = []string{v}
+		}
+		for _, list := range lists {
+			snap, err := rt.Connections.TakeSnapshot(r.Context(), list, service.SnapshotManual)
+			if err != nil {
+				return err
+			}
+			snap.Entries = nil
+			res = append(res, snap)
+		}
+		return nil
+	})
+	if err != nil {
+		// снимки остальных роутеров уже сохранены — отдаём их вместе с ошибками
+		writeRouterResults(w, results, err, map[string]any{"snapshots": res})
+		return
+	}
+	writeJSON(w, 200, res)
+}
+
+func (h *Handler) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	rt, id, ok := h.snapshotRouter(w, r)
+	if !ok {
+		return
+	}
+	snap, err := rt.Connections.Snapshot(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeJSON(w, 200, snap)
+}
+
+func (h *Handler) getSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	rt, id, ok := h.snapshotRouter(w, r)
+	if !ok {
+		return
+	}
+	d, err := rt.Connections.SnapshotDiff(r.Context(), id)
+	if err != nil {
+		writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error()})
+		return
+	}
+	writeJSON(w, 200, d)
+}
+
+func (h *Handler) postSnapshotRestore(w http.ResponseWriter, r *http.Request) {
+	rt, id, ok := h.snapshotRouter(w, r)
+	if !ok {
+		return
+	}
+	d, err := rt.Connections.RestoreSnapshot(r.Context(), id)
+	if err != nil {
+		// частично применённое восстановление: отдаём, что успели сделать
+		writeJSON(w, 500, map[string]any{"error": rt.Name + ": " + err.Error(), "restore": d})
+		return
+	}
+	writeJSON(w, 200, d)
+}
+
+// snapshotRouter находит роутер, которому принадлежит снимок {id}; при ошибке ответ уже записан.
+func (h *Handler) snapshotRouter(w http.ResponseWriter, r *http.Request) (*service.Router, int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": "bad id"})
+		return nil, 0, false
+	}
+	rt, err := h.findSnapshotRouter(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, err)
+		return nil, 0, false
+	}
+	return rt, id, true
+}
+
+func (h *Handler) findSnapshotRouter(ctx context.Context, id int64) (*service.Router, error) {
+	for _, rt := range h.routers {
+		_, err := rt.Connections.Snapshot(ctx, id)
+		if err == nil {
+			return rt, nil
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, storage.ErrNotFound
+}
@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mikrotik-parser-go/internal/service"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamPing — как часто слать keep-alive, чтобы прокси не рвали тихое соединение.
+const streamPing = 15 * time.Second
+
+// streamSubscribe разбирает ?router=&srcIp=&domain= и подписывает на брокер.
+func (h *Handler) streamSubscribe(w http.ResponseWriter, r *http.Request) (*service.Subscription, bool) {
+	routers, err := h.selectRouters(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]any{"error": err.Error()})
+		return nil, false
+	}
+	names := make([]string, 0, len(routers))
+	for _, rt := range routers {
+		names = append(names, rt.Name)
+	}
+
+	return h.broker.Subscribe(service.StreamFilter{
+		Routers: names,
+		SrcIP:   r.URL.Query().Get("srcIp"),
+		Domain:  r.URL.Query().Get("domain"),
+	}), true
+}
+
+// getStream — Server-Sent Events: event "snapshot" с текущим состоянием роутера,
+// затем event "delta" на каждый тик коллектора с изменениями.
+func (h *Handler) getStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, 500, map[string]any{"error": "streaming is not supported"})
+		return
+	}
+	sub, ok := h.streamSubscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: не буферизовать
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case u, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(u)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", u.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// getStreamWS — то же по WebSocket: каждое сообщение — StreamUpdate в JSON.
+func (h *Handler) getStreamWS(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.streamSubscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.broker.Unsubscribe(sub)
+
+	// браузер шлёт cookie и на чужие страницы: пускаем только свои и разрешённые CORS источники
+	upgrader := websocket.Upgrader{CheckOrigin: h.originAllowed}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade уже ответил клиенту
+	}
+	defer conn.Close()
+
+	// входящие сообщения не ждём, но читать нужно, чтобы заметить закрытие
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				return
+			}
+		case u, ok := <-sub.C:
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(u); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"errors"
+	"log"
 
 	migrate "github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
@@ -22,6 +24,10 @@ func Up(sqliteDSN string) error {
 	}
 	defer db.Close()
 
+	if err := enableIncrementalVacuum(db); err != nil {
+		return err
+	}
+
 	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
 	if err != nil {
 		return err
@@ -42,3 +48,41 @@ func Up(sqliteDSN string) error {
 	}
 	return nil
 }
+
+// enableIncrementalVacuum включает auto_vacuum=INCREMENTAL, чтобы чистка старых
+// записей могла возвращать место (PRAGMA incremental_vacuum). Для новой базы режим
+// применяется сразу, для существующей — один раз через VACUUM.
+func enableIncrementalVacuum(db *sql.DB) error {
+	ctx := context.Background()
+
+	// pragma действует на соединение, поэтому всё делаем на одном
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, `PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return err
+	}
+	if mode == 2 {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA auto_vacuum=INCREMENTAL`); err != nil {
+		return err
+	}
+
+	var tables int
+	if err := conn.QueryRowContext(ctx, `select count(*) from sqlite_schema where type = 'table'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+
+	log.Println("sqlite: switching to auto_vacuum=INCREMENTAL (one-time VACUUM)")
+	_, err = conn.ExecContext(ctx, `VACUUM`)
+	return err
+}
@@ -0,0 +1,54 @@
+alter table connections add column router text not null default 'default';
+
+create index if not exists idx_connections_router
+    on connections (router);
+
+
+create table domain_conn_counts_new (
+                                        router text not null default 'default',
+                                        dst_dns text not null,
+                                        active_connections integer not null,
+                                        updated_at text not null,
+                                        primary key (router, dst_dns)
+);
+
+insert into domain_conn_counts_new (router, dst_dns, active_connections, updated_at)
+select 'default', dst_dns, active_connections, updated_at
+  from domain_conn_counts;
+
+drop table domain_conn_counts;
+
+alter table domain_conn_counts_new rename to domain_conn_counts;
+
+create index if not exists idx_domain_conn_counts_active
+    on domain_conn_counts (active_connections);
+
+create index if not exists idx_domain_conn_counts_updated
+    on domain_conn_counts (updated_at);
+
+
+create table dst_conn_counts_new (
+                                     router text not null default 'default',
+                                     dst_ip text not null,
+                                     dst_dns text not null default '',
+                                     active_connections integer not null,
+                                     updated_at text not null,
+                                     primary key (router, dst_ip, dst_dns)
+);
+
+insert into dst_conn_counts_new (router, dst_ip, dst_dns, active_connections, updated_at)
+select 'default', dst_ip, dst_dns, active_connections, updated_at
+  from dst_conn_counts;
+
+drop table dst_conn_counts;
+
+alter table dst_conn_counts_new rename to dst_conn_counts;
+
+create index if not exists idx_dst_conn_counts_dns
+    on dst_conn_counts (dst_dns);
+
+create index if not exists idx_dst_conn_counts_active
+    on dst_conn_counts (active_connections);
+
+create index if not exists idx_dst_conn_counts_updated
+    on dst_conn_counts (updated_at);
@@ -0,0 +1,37 @@
+-- connections раньше не заполнялась; теперь это сессии: один 5-tuple, пока он виден в conntrack
+drop table if exists connections;
+
+create table connections (
+                             id integer primary key,
+                             router text not null default 'default',
+                             protocol text not null default '',
+                             src_ip text not null,
+                             src_port text not null default '',
+                             dst_ip text not null,
+                             dst_port text not null default '',
+                             dst_dns text not null default '',
+                             host_name text not null default '',
+                             first_seen text not null,
+                             last_seen text not null,
+                             open integer not null default 1
+);
+
+-- открытая сессия по 5-tuple может быть только одна (для upsert каждого тика)
+create unique index if not exists idx_connections_open_key
+    on connections (router, protocol, src_ip, src_port, dst_ip, dst_port)
+    where open = 1;
+
+create index if not exists idx_connections_router_open
+    on connections (router, open, last_seen);
+
+create index if not exists idx_connections_last_seen
+    on connections (last_seen);
+
+create index if not exists idx_connections_src_ip
+    on connections (src_ip, last_seen);
+
+create index if not exists idx_connections_dst_ip
+    on connections (dst_ip, last_seen);
+
+create index if not exists idx_connections_dst_dns
+    on connections (dst_dns);
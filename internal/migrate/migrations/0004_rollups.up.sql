@@ -0,0 +1,44 @@
+-- число тиков коллектора в бакете: нужно, чтобы avg/min учитывали тики, где домена не было
+create table if not exists collect_ticks (
+                                             router text not null,
+                                             bucket text not null, -- minute | hour | day
+                                             bucket_start text not null,
+                                             ticks integer not null,
+                                             primary key (router, bucket, bucket_start)
+);
+
+create index if not exists idx_collect_ticks_start
+    on collect_ticks (bucket, bucket_start);
+
+
+create table if not exists domain_conn_rollups (
+                                                   router text not null,
+                                                   bucket text not null,
+                                                   bucket_start text not null,
+                                                   dst_dns text not null,
+                                                   min_connections integer not null,
+                                                   max_connections integer not null,
+                                                   sum_connections integer not null,
+                                                   samples integer not null,
+                                                   primary key (router, bucket, dst_dns, bucket_start)
+);
+
+create index if not exists idx_domain_conn_rollups_start
+    on domain_conn_rollups (bucket, bucket_start);
+
+
+create table if not exists dst_conn_rollups (
+                                                router text not null,
+                                                bucket text not null,
+                                                bucket_start text not null,
+                                                dst_ip text not null,
+                                                dst_dns text not null default '',
+                                                min_connections integer not null,
+                                                max_connections integer not null,
+                                                sum_connections integer not null,
+                                                samples integer not null,
+                                                primary key (router, bucket, dst_ip, dst_dns, bucket_start)
+);
+
+create index if not exists idx_dst_conn_rollups_start
+    on dst_conn_rollups (bucket, bucket_start);
@@ -0,0 +1,35 @@
+-- счётчики conntrack: у сессии — последние увиденные значения,
+-- в агрегатах — сумма приращений между тиками
+alter table connections add column orig_bytes integer not null default 0;
+alter table connections add column repl_bytes integer not null default 0;
+
+alter table domain_conn_rollups add column orig_bytes integer not null default 0;
+alter table domain_conn_rollups add column repl_bytes integer not null default 0;
+alter table domain_conn_rollups add column orig_packets integer not null default 0;
+alter table domain_conn_rollups add column repl_packets integer not null default 0;
+
+alter table dst_conn_rollups add column orig_bytes integer not null default 0;
+alter table dst_conn_rollups add column repl_bytes integer not null default 0;
+alter table dst_conn_rollups add column orig_packets integer not null default 0;
+alter table dst_conn_rollups add column repl_packets integer not null default 0;
+
+
+-- трафик LAN-клиентов (по src_ip), в том числе соединений без домена
+create table if not exists host_rollups (
+                                            router text not null,
+                                            bucket text not null,
+                                            bucket_start text not null,
+                                            src_ip text not null,
+                                            min_connections integer not null,
+                                            max_connections integer not null,
+                                            sum_connections integer not null,
+                                            samples integer not null,
+                                            orig_bytes integer not null default 0,
+                                            repl_bytes integer not null default 0,
+                                            orig_packets integer not null default 0,
+                                            repl_packets integer not null default 0,
+                                            primary key (router, bucket, src_ip, bucket_start)
+);
+
+create index if not exists idx_host_rollups_start
+    on host_rollups (bucket, bucket_start);
@@ -0,0 +1,4 @@
+-- CNAME-цепочка от запрошенного имени до имени A/AAAA-записи и другие имена того же адреса;
+-- списки через запятую
+alter table dst_conn_counts add column cnames text not null default '';
+alter table dst_conn_counts add column other_names text not null default '';
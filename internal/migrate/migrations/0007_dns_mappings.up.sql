@@ -0,0 +1,15 @@
+-- память адрес -> домен из опросов DNS-кэша роутера: живёт дольше ttl записи в кэше
+create table if not exists dns_mappings (
+                                            router text not null,
+                                            ip text not null,
+                                            name text not null,
+                                            cnames text not null default '',
+                                            ttl_seconds integer not null default 0,
+                                            first_seen text not null,
+                                            last_seen text not null,
+                                            expires_at text not null, -- last_seen + ttl
+                                            primary key (router, ip, name)
+);
+
+create index if not exists idx_dns_mappings_expires
+    on dns_mappings (expires_at);
@@ -0,0 +1,31 @@
+-- пользователи API: пароль — bcrypt, роль — viewer, operator или admin
+create table if not exists users (
+                                     id integer primary key autoincrement,
+                                     name text not null unique,
+                                     password_hash text not null,
+                                     role text not null,
+                                     created_at text not null
+);
+
+-- долгоживущие токены API (Authorization: Bearer ...); хранится только sha256 токена
+create table if not exists api_tokens (
+                                          id integer primary key autoincrement,
+                                          user_id integer not null references users (id) on delete cascade,
+                                          name text not null,
+                                          token_hash text not null unique,
+                                          role text not null, -- не выше роли владельца
+                                          created_at text not null,
+                                          last_used_at text not null default '',
+                                          expires_at text not null default '' -- '' — бессрочный
+);
+
+-- сессии SPA (cookie); хранится только sha256 идентификатора
+create table if not exists sessions (
+                                        id_hash text primary key,
+                                        user_id integer not null references users (id) on delete cascade,
+                                        created_at text not null,
+                                        expires_at text not null
+);
+
+create index if not exists idx_sessions_expires
+    on sessions (expires_at);
@@ -0,0 +1,23 @@
+-- журнал изменений address-листов: кто, когда, что и с каким результатом
+create table if not exists audit_log (
+                                         id integer primary key autoincrement,
+                                         at text not null,
+                                         router text not null,
+                                         actor text not null default '',      -- имя пользователя; '' — аутентификация выключена
+                                         via text not null default '',        -- session | token
+                                         origin text not null default '',     -- адрес клиента
+                                         user_agent text not null default '',
+                                         list_name text not null,
+                                         family text not null,                -- ip | ipv6
+                                         address text not null,
+                                         before_state text not null default '', -- absent | enabled | disabled; '' — неизвестно
+                                         after_state text not null default '',
+                                         result text not null,                -- ok | error
+                                         error text not null default ''
+);
+
+create index if not exists idx_audit_log_at
+    on audit_log (at);
+
+create index if not exists idx_audit_log_address
+    on audit_log (address);
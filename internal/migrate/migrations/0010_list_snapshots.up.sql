@@ -0,0 +1,17 @@
+-- снимки address-листов для отката: статические записи обоих семейств одним JSON
+create table if not exists list_snapshots (
+                                              id integer primary key autoincrement,
+                                              router text not null,
+                                              list_name text not null,
+                                              taken_at text not null,
+                                              reason text not null,            -- periodic | before-write | before-restore | manual
+                                              actor text not null default '',
+                                              entry_count integer not null default 0,
+                                              entries text not null            -- JSON [{family, address, disabled, comment}]
+);
+
+create index if not exists idx_list_snapshots_list
+    on list_snapshots (router, list_name, taken_at);
+
+create index if not exists idx_list_snapshots_taken
+    on list_snapshots (taken_at);
@@ -0,0 +1,3 @@
+-- комментарий и timeout записи address-листа в журнале изменений
+alter table audit_log add column comment text not null default '';
+alter table audit_log add column timeout text not null default '';
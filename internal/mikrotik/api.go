@@ -0,0 +1,96 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Family — семейство адресов; значение — корень меню RouterOS (/ip или /ipv6).
+type Family string
+
+const (
+	IPv4 Family = "ip"
+	IPv6 Family = "ipv6"
+)
+
+// FamilyOf: IPv6 для адресов и префиксов IPv6, иначе IPv4 (в том числе для доменов в address-list).
+func FamilyOf(address string) Family {
+	if p, err := netip.ParsePrefix(address); err == nil {
+		address = p.Addr().String()
+	}
+	if a, err := netip.ParseAddr(address); err == nil && a.Is6() && !a.Is4In6() {
+		return IPv6
+	}
+	return IPv4
+}
+
+func (f Family) path(p string) string { return "/" + string(f) + p }
+
+// API — операции с роутером, которые нужны сервисам.
+// Реализации: Client (бинарный API, порты 8728/8729) и RestClient (REST API RouterOS 7).
+type API interface {
+	DNSCache(ctx context.Context, q Query) ([]map[string]string, error) // все записи (/ip/dns/cache/all) с type
+	DHCPLeases(ctx context.Context) ([]map[string]string, error)
+	DHCPv6Bindings(ctx context.Context) ([]map[string]string, error)
+	FirewallConnections(ctx context.Context, fam Family, q Query) ([]map[string]string, error)
+
+	AddressListIgnoreVPN(ctx context.Context, fam Family, listName string) ([]map[string]string, error)
+	AddressListSetDisabled(ctx context.Context, fam Family, id string, disabled bool, opts EntryOptions) error
+	AddressListAdd(ctx context.Context, fam Family, listName, address string, opts EntryOptions) error
+	AddressListRemove(ctx context.Context, fam Family, id string) error
+
+	State() State
+	Close() error
+}
+
+// EntryOptions — необязательные свойства записи address-list; незаданные не передаются.
+type EntryOptions struct {
+	Comment *string       // nil — при set оставить прежний комментарий, "" — очистить
+	Timeout time.Duration // запись удалится сама через Timeout; 0 — постоянная
+}
+
+// CommentText — комментарий для журнала и ответов ("" — не задан или очищается).
+func (o EntryOptions) CommentText() string {
+	if o.Comment == nil {
+		return ""
+	}
+	return *o.Comment
+}
+
+// props — свойства для add/set в именах RouterOS.
+func (o EntryOptions) props() map[string]string {
+	p := map[string]string{}
+	if o.Comment != nil {
+		p["comment"] = *o.Comment
+	}
+	if o.Timeout > 0 {
+		p["timeout"] = FormatDuration(o.Timeout)
+	}
+	return p
+}
+
+// FormatDuration — длительность в формате RouterOS ("1d2h30m", "45s").
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "0s"
+	}
+	var out string
+	for _, u := range []struct {
+		suffix string
+		unit   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := d / u.unit; n > 0 {
+			out += fmt.Sprintf("%d%s", n, u.suffix)
+			d -= n * u.unit
+		}
+	}
+	return out
+}
+
+var (
+	_ API = (*Client)(nil)
+	_ API = (*RestClient)(nil)
+)
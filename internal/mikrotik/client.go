@@ -2,7 +2,11 @@ package mikrotik
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,13 +18,36 @@ type Client struct {
 	addr string
 	user string
 	pass string
+	tls  *tls.Config // nil — обычный API без шифрования
 
-	mu sync.Mutex
-	c  *routeros.Client
+	mu   sync.Mutex
+	c    *routeros.Client
+	conn net.Conn // сокет под c, нужен для дедлайнов команд
+
+	state connState
+
+	// потоковый режим (Follow): зеркала таблиц по меню
+	followMu sync.Mutex
+	mirrors  map[string]*mirror
 }
 
 func New(addr, user, pass string) *Client {
-	return &Client{addr: addr, user: user, pass: pass}
+	return &Client{addr: withDefaultPort(addr, "8728"), user: user, pass: pass}
+}
+
+// NewTLS создаёт клиент API-SSL (порт по умолчанию 8729).
+func NewTLS(addr, user, pass string, tlsConfig *tls.Config) *Client {
+	return &Client{addr: withDefaultPort(addr, "8729"), user: user, pass: pass, tls: tlsConfig}
+}
+
+func withDefaultPort(addr, port string) string {
+	if addr == "" {
+		return addr
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port)
 }
 
 func (m *Client) Connect(ctx context.Context) error {
@@ -33,16 +60,57 @@ func (m *Client) Connect(ctx context.Context) error {
 	if m.addr == "" {
 		return errors.New("mikrotik addr is empty")
 	}
+	if err := m.state.checkBackoff(); err != nil {
+		return err
+	}
 
-	// DialContext: connects and logs in using context.
-	c, err := routeros.DialContext(ctx, m.addr, m.user, m.pass)
+	c, conn, err := m.dial(ctx)
 	if err != nil {
+		m.state.dialFailed(err)
 		return err
 	}
-	m.c = c
+	m.c, m.conn = c, conn
+	m.state.connected()
 	return nil
 }
 
+// dial подключается и логинится. Сокет открываем сами, а не через routeros.Dial*,
+// чтобы ставить на него дедлайны: синхронный RunContext библиотеки контекст не учитывает.
+func (m *Client) dial(ctx context.Context) (*routeros.Client, net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.tls != nil {
+		conn, err = (&tls.Dialer{Config: m.tls}).DialContext(ctx, "tcp", m.addr)
+		if err != nil {
+			return nil, nil, describeTLSError(m.addr, err)
+		}
+	} else {
+		conn, err = new(net.Dialer).DialContext(ctx, "tcp", m.addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not connect to router os: %w", err)
+		}
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+
+	c, err := routeros.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if err := c.LoginContext(ctx, m.user, m.pass); err != nil {
+		_ = c.Close()
+		return nil, nil, fmt.Errorf("could not login: %w", err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return c, conn, nil
+}
+
 func (m *Client) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -51,10 +119,20 @@ func (m *Client) Close() error {
 		return nil
 	}
 	err := m.c.Close()
-	m.c = nil
+	m.c, m.conn = nil, nil
+	m.state.disconnected(nil)
 	return err
 }
 
+// State возвращает текущее состояние подключения.
+func (m *Client) State() State {
+	st := m.state.snapshot(m.addr, m.tls != nil)
+	st.Streaming, st.StreamSyncedAt = m.streamState()
+	return st
+}
+
+// run выполняет команду. Если сессия оборвалась (EOF, таймаут, сброс соединения),
+// она закрывается, а идемпотентные команды (print) повторяются один раз на новом подключении.
 func (m *Client) run(ctx context.Context, sentence ...string) (*routeros.Reply, error) {
 	// Чтобы не зависать навсегда, ограничим RunContext локальным таймаутом.
 	if _, ok := ctx.Deadline(); !ok {
@@ -63,14 +141,39 @@ func (m *Client) run(ctx context.Context, sentence ...string) (*routeros.Reply,
 		defer cancel()
 	}
 
+	r, broken, err := m.runOnce(ctx, sentence)
+	if broken && isIdempotent(sentence) && ctx.Err() == nil {
+		r, _, err = m.runOnce(ctx, sentence)
+	}
+	return r, err
+}
+
+// runOnce: broken == true, если упала уже установленная сессия (а не подключение).
+func (m *Client) runOnce(ctx context.Context, sentence []string) (*routeros.Reply, bool, error) {
 	if err := m.Connect(ctx); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return m.c.RunContext(ctx, sentence...)
+	if m.c == nil {
+		return nil, true, errSessionClosed
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = m.conn.SetDeadline(dl)
+	}
+	r, err := m.c.RunContext(ctx, sentence...)
+	if err != nil && isSessionError(err) {
+		// сессия мертва: закрываем, следующий вызов переподключится
+		_ = m.c.Close()
+		m.c, m.conn = nil, nil
+		m.state.disconnected(err)
+		return nil, true, err
+	}
+	_ = m.conn.SetDeadline(time.Time{})
+	return r, false, err
 }
 
 // replyToMaps конвертирует []*proto.Sentence -> []map[string]string через Sentence.Map.
@@ -98,8 +201,11 @@ func replyToMaps(r *routeros.Reply) []map[string]string {
 	return out
 }
 
-func (m *Client) DNSCache(ctx context.Context) ([]map[string]string, error) {
-	r, err := m.run(ctx, "/ip/dns/cache/print")
+func (m *Client) DNSCache(ctx context.Context, q Query) ([]map[string]string, error) {
+	if rows, ok := m.mirrored("/ip/dns/cache/all"); ok {
+		return filterRows(rows, q.Filter), nil
+	}
+	r, err := m.run(ctx, append([]string{"/ip/dns/cache/all/print"}, q.words()...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -114,17 +220,29 @@ func (m *Client) DHCPLeases(ctx context.Context) ([]map[string]string, error) {
 	return replyToMaps(r), nil
 }
 
-func (m *Client) FirewallConnections(ctx context.Context) ([]map[string]string, error) {
-	r, err := m.run(ctx, "/ip/firewall/connection/print")
+func (m *Client) DHCPv6Bindings(ctx context.Context) ([]map[string]string, error) {
+	r, err := m.run(ctx, "/ipv6/dhcp-server/binding/print")
+	if err != nil {
+		return nil, err
+	}
+	return replyToMaps(r), nil
+}
+
+func (m *Client) FirewallConnections(ctx context.Context, fam Family, q Query) ([]map[string]string, error) {
+	// зеркало хранит строки целиком: .proplist не нужен, фильтр — на нашей стороне
+	if rows, ok := m.mirrored(fam.path("/firewall/connection")); ok {
+		return filterRows(rows, q.Filter), nil
+	}
+	r, err := m.run(ctx, append([]string{fam.path("/firewall/connection/print")}, q.words()...)...)
 	if err != nil {
 		return nil, err
 	}
 	return replyToMaps(r), nil
 }
 
-func (m *Client) AddressListIgnoreVPN(ctx context.Context, listName string) ([]map[string]string, error) {
+func (m *Client) AddressListIgnoreVPN(ctx context.Context, fam Family, listName string) ([]map[string]string, error) {
 	r, err := m.run(ctx,
-		"/ip/firewall/address-list/print",
+		fam.path("/firewall/address-list/print"),
 		"?list="+listName,
 	)
 	if err != nil {
@@ -133,24 +251,47 @@ func (m *Client) AddressListIgnoreVPN(ctx context.Context, listName string) ([]m
 	return replyToMaps(r), nil
 }
 
-func (m *Client) AddressListSetDisabled(ctx context.Context, id string, disabled bool) error {
+func (m *Client) AddressListSetDisabled(ctx context.Context, fam Family, id string, disabled bool, opts EntryOptions) error {
 	val := "no"
 	if disabled {
 		val = "yes"
 	}
-	_, err := m.run(ctx,
-		"/ip/firewall/address-list/set",
-		"=disabled="+val,
-		"=.id="+id,
-	)
+	words := []string{
+		fam.path("/firewall/address-list/set"),
+		"=disabled=" + val,
+		"=.id=" + id,
+	}
+	_, err := m.run(ctx, appendProps(words, opts.props())...)
 	return err
 }
 
-func (m *Client) AddressListAdd(ctx context.Context, listName, address string) error {
+func (m *Client) AddressListAdd(ctx context.Context, fam Family, listName, address string, opts EntryOptions) error {
+	words := []string{
+		fam.path("/firewall/address-list/add"),
+		"=list=" + listName,
+		"=address=" + address,
+	}
+	_, err := m.run(ctx, appendProps(words, opts.props())...)
+	return err
+}
+
+// appendProps добавляет свойства словами =key=value в стабильном порядке.
+func appendProps(words []string, props map[string]string) []string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		words = append(words, "="+k+"="+props[k])
+	}
+	return words
+}
+
+func (m *Client) AddressListRemove(ctx context.Context, fam Family, id string) error {
 	_, err := m.run(ctx,
-		"/ip/firewall/address-list/add",
-		"=list="+listName,
-		"=address="+address,
+		fam.path("/firewall/address-list/remove"),
+		"=.id="+id,
 	)
 	return err
 }
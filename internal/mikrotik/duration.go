@@ -0,0 +1,68 @@
+package mikrotik
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration разбирает длительность в формате RouterOS: "4m30s", "1d2h", "2w1d",
+// "1h5m3s120ms" или "00:04:30" (ttl, timeout, life-time в выводе print).
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	// hh:mm:ss
+	if parts := strings.Split(s, ":"); len(parts) == 3 {
+		var d time.Duration
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			n, err := strconv.ParseUint(parts[i], 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+			d += time.Duration(n) * unit
+		}
+		return d, nil
+	}
+
+	var d time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		n, _ := strconv.ParseInt(s[:i], 10, 64)
+		s = s[i:]
+
+		j := 0
+		for j < len(s) && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		var unit time.Duration
+		switch s[:j] {
+		case "w":
+			unit = 7 * 24 * time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		case "h":
+			unit = time.Hour
+		case "m":
+			unit = time.Minute
+		case "s", "":
+			unit = time.Second
+		case "ms":
+			unit = time.Millisecond
+		default:
+			return 0, fmt.Errorf("bad duration unit %q", s[:j])
+		}
+		d += time.Duration(n) * unit
+		s = s[j:]
+	}
+	return d, nil
+}
@@ -0,0 +1,6 @@
+package mikrotik
+
+// Mirrored открывает зеркало потокового режима внешним тестам (mikrotik_test).
+func (m *Client) Mirrored(path string) ([]map[string]string, bool) {
+	return m.mirrored(path)
+}
@@ -0,0 +1,220 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type demoHost struct {
+	ip, mac, name string
+	ip6           string // пусто — клиент без IPv6
+}
+
+type demoSite struct {
+	name string
+	ip   string
+	ip6  string // AAAA; пусто — только IPv4
+	port string
+
+	cnames []string // цепочка CNAME от name; адрес — у последнего имени
+}
+
+var (
+	demoHosts = []demoHost{
+		{"192.168.88.10", "3C:22:FB:10:00:01", "laptop", "2001:db8:88::10"},
+		{"192.168.88.11", "A4:83:E7:10:00:02", "phone", "2001:db8:88::11"},
+		{"192.168.88.12", "F0:27:2D:10:00:03", "living-room-tv", ""},
+		{"192.168.88.13", "00:D8:61:10:00:04", "desktop", "2001:db8:88::13"},
+	}
+
+	demoSites = []demoSite{
+		{"www.youtube.com", "142.250.74.14", "2a00:1450:4010:c0e::5b", "443", nil},
+		{"rr1---sn-4g5e6nzz.googlevideo.com", "173.194.160.6", "2a00:1450:4010:c0e::6", "443", nil},
+		{"rr3---sn-4g5e6nzz.googlevideo.com", "173.194.160.8", "", "443", nil},
+		{"www.netflix.com", "54.74.73.31", "2a05:d018:76c:b683::1f", "443", nil},
+		{"api.telegram.org", "149.154.167.220", "2001:67c:4e8:f004::9", "443", nil},
+		{"github.com", "140.82.121.4", "", "443", nil},
+		{"steamcommunity.com", "104.124.14.7", "", "443", nil},
+		{"time.cloudflare.com", "162.159.200.1", "2606:4700:f1::1", "123", nil},
+		// CDN: два сайта за одним адресом Akamai
+		{"www.apple.com", "23.48.209.10", "", "443", []string{"www.apple.com.edgekey.net", "e6858.dscx.akamaiedge.net"}},
+		{"www.icloud.com", "23.48.209.10", "", "443", []string{"www.icloud.com.edgekey.net", "e6858.dscx.akamaiedge.net"}},
+	}
+)
+
+// SeedDemo заполняет таблицы правдоподобными данными домашней сети.
+func SeedDemo(s *Server) {
+	// /ip/dns/cache/all: CNAME-цепочки и адресные записи; общие записи CDN — один раз
+	var dns []map[string]string
+	seen := map[string]bool{}
+	record := func(name, typ, data, ttl string) {
+		if k := name + " " + typ + " " + data; !seen[k] {
+			seen[k] = true
+			dns = append(dns, map[string]string{"name": name, "type": typ, "data": data, "ttl": ttl})
+		}
+	}
+	for i, site := range demoSites {
+		name := site.name
+		for _, c := range site.cnames {
+			// у сайтов с разным ttl видно, какой резолвился последним
+			record(name, "CNAME", c, fmt.Sprintf("%dm", 50+i))
+			name = c
+		}
+		record(name, "A", site.ip, "4m30s")
+		if site.ip6 != "" {
+			record(name, "AAAA", site.ip6, "4m30s")
+		}
+	}
+	s.SetTable("/ip/dns/cache/all", dns)
+
+	leases := make([]map[string]string, 0, len(demoHosts))
+	for _, h := range demoHosts {
+		leases = append(leases, map[string]string{
+			"address":          h.ip,
+			"active-address":   h.ip,
+			"mac-address":      h.mac,
+			"active-host-name": h.name,
+			"host-name":        h.name,
+			"status":           "bound",
+			"dynamic":          "true",
+			"disabled":         "false",
+		})
+	}
+	s.SetTable("/ip/dhcp-server/lease", leases)
+
+	var bindings []map[string]string
+	for _, h := range demoHosts {
+		if h.ip6 != "" {
+			bindings = append(bindings, map[string]string{
+				"address": h.ip6 + "/128",
+				"duid":    "0x00030001" + strings.ReplaceAll(strings.ToLower(h.mac), ":", ""),
+				"server":  "dhcp6-lan",
+				"status":  "bound",
+				"comment": h.name,
+			})
+		}
+	}
+	s.SetTable("/ipv6/dhcp-server/binding", bindings)
+
+	conns := make([]map[string]string, 0, 24)
+	for range 24 {
+		conns = append(conns, demoConnection())
+	}
+	s.SetTable("/ip/firewall/connection", conns)
+
+	conns6 := make([]map[string]string, 0, 8)
+	for range 8 {
+		conns6 = append(conns6, demoConnection6())
+	}
+	s.SetTable("/ipv6/firewall/connection", conns6)
+
+	s.SetTable("/ip/firewall/address-list", []map[string]string{
+		{"list": "ignoreVpn", "address": "www.youtube.com", "disabled": "false", "dynamic": "false"},
+		{"list": "ignoreVpn", "address": "www.netflix.com", "disabled": "true", "dynamic": "false"},
+		{"list": "ignoreLanToVpn", "address": "192.168.88.12", "disabled": "false", "dynamic": "false", "comment": "tv"},
+	})
+	s.SetTable("/ipv6/firewall/address-list", []map[string]string{
+		{"list": "ignoreVpn", "address": "www.youtube.com", "disabled": "false", "dynamic": "false"},
+		{"list": "ignoreVpn", "address": "www.netflix.com", "disabled": "true", "dynamic": "false"},
+	})
+}
+
+func demoConnection() map[string]string {
+	h := demoHosts[rand.IntN(len(demoHosts))]
+	site := demoSites[rand.IntN(len(demoSites))]
+	return demoConnRow(site.port,
+		h.ip+":"+strconv.Itoa(40000+rand.IntN(20000)),
+		site.ip+":"+site.port,
+	)
+}
+
+// demoConnection6 — IPv6-соединение; адреса с портом через двоеточие, как в conntrack RouterOS.
+func demoConnection6() map[string]string {
+	var hosts []demoHost
+	for _, h := range demoHosts {
+		if h.ip6 != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	var sites []demoSite
+	for _, site := range demoSites {
+		if site.ip6 != "" {
+			sites = append(sites, site)
+		}
+	}
+	h := hosts[rand.IntN(len(hosts))]
+	site := sites[rand.IntN(len(sites))]
+	return demoConnRow(site.port,
+		h.ip6+":"+strconv.Itoa(40000+rand.IntN(20000)),
+		site.ip6+":"+site.port,
+	)
+}
+
+func demoConnRow(port, src, dst string) map[string]string {
+	proto := "tcp"
+	if port == "123" {
+		proto = "udp"
+	}
+
+	row := map[string]string{
+		"protocol":     proto,
+		"src-address":  src,
+		"dst-address":  dst,
+		"orig-bytes":   strconv.Itoa(rand.IntN(20000)),
+		"repl-bytes":   strconv.Itoa(rand.IntN(200000)),
+		"orig-packets": strconv.Itoa(rand.IntN(50)),
+		"repl-packets": strconv.Itoa(rand.IntN(200)),
+		"timeout":      "23h59m50s",
+	}
+	if proto == "tcp" {
+		row["tcp-state"] = "established"
+	}
+	return row
+}
+
+// Churn раз в interval закрывает часть соединений, открывает новые и наращивает
+// счётчики байт у остальных — чтобы демо выглядело живым. Блокируется до отмены ctx.
+func (s *Server) Churn(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Update("/ip/firewall/connection", churnRows(24, demoConnection))
+			s.Update("/ipv6/firewall/connection", churnRows(8, demoConnection6))
+		}
+	}
+}
+
+// churnRows закрывает часть соединений, наращивает счётчики остальных и добирает новыми до n.
+func churnRows(n int, newRow func() map[string]string) func([]map[string]string) []map[string]string {
+	return func(rows []map[string]string) []map[string]string {
+		out := rows[:0]
+		for _, r := range rows {
+			if rand.IntN(5) == 0 {
+				continue
+			}
+			r["orig-bytes"] = grow(r["orig-bytes"], 5000)
+			r["repl-bytes"] = grow(r["repl-bytes"], 80000)
+			r["orig-packets"] = grow(r["orig-packets"], 20)
+			r["repl-packets"] = grow(r["repl-packets"], 80)
+			out = append(out, r)
+		}
+		for len(out) < n {
+			out = append(out, newRow())
+		}
+		return out
+	}
+}
+
+func grow(v string, upTo int) string {
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return fmt.Sprint(n + int64(rand.IntN(upTo)))
+}
@@ -0,0 +1,657 @@
+// Package fake — RouterOS API сервер в памяти процесса: для тестов и демо-режима.
+//
+// Понимает протокол API (те же слова и длины, что у порта 8728) и команды
+// /login, .../print, .../add, .../set, .../remove, /cancel над таблицами,
+// которые задаются через SetTable. Записи (add/set/remove) сохраняются в журнал Writes.
+// print =follow= / =follow-only= держит команду открытой и присылает изменения
+// таблицы (удалённые строки — с .dead=true) до /cancel по тегу.
+package fake
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+
+	"github.com/go-routeros/routeros/v3/proto"
+)
+
+// Command — записывающая команда, полученная сервером.
+type Command struct {
+	Path string            // например /ip/firewall/address-list/add
+	Args map[string]string // =key=value без "="
+	At   time.Time
+}
+
+type Server struct {
+	// Если User пустой — принимается любой логин.
+	User     string
+	Password string
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	tables map[string][]map[string]string
+	nextID map[string]int // счётчик .id по таблице, как в RouterOS
+	writes []Command
+
+	// подписки print follow по таблице
+	watchers map[string]map[chan struct{}]struct{}
+
+	wg sync.WaitGroup
+}
+
+func New() *Server {
+	return &Server{
+		conns:    map[net.Conn]struct{}{},
+		tables:   map[string][]map[string]string{},
+		nextID:   map[string]int{},
+		watchers: map[string]map[chan struct{}]struct{}{},
+	}
+}
+
+// Start слушает addr (например "127.0.0.1:0") и обслуживает подключения в фоне.
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr — адрес listener'а (host:port) для mikrotik.New.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// DropConnections рвёт все текущие сессии (проверка переподключения клиента).
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// SetTable задаёт содержимое таблицы, path — меню без команды, например "/ip/dns/cache".
+// Строкам без ".id" назначается id.
+func (s *Server) SetTable(path string, rows []map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := make([]map[string]string, 0, len(rows))
+	for _, r := range rows {
+		row := cloneRow(r)
+		if row[".id"] == "" {
+			row[".id"] = s.newID(path)
+		}
+		cp = append(cp, row)
+	}
+	s.tables[path] = cp
+	s.changed(path)
+}
+
+// Table возвращает копию таблицы.
+func (s *Server) Table(path string) []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]map[string]string, 0, len(s.tables[path]))
+	for _, r := range s.tables[path] {
+		out = append(out, cloneRow(r))
+	}
+	return out
+}
+
+// Update меняет таблицу под блокировкой (для демо и сценариев тестов).
+func (s *Server) Update(path string, fn func(rows []map[string]string) []map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := fn(s.tables[path])
+	for _, r := range rows {
+		if r[".id"] == "" {
+			r[".id"] = s.newID(path)
+		}
+	}
+	s.tables[path] = rows
+	s.changed(path)
+}
+
+// Writes — журнал записывающих команд в порядке получения.
+func (s *Server) Writes() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Command(nil), s.writes...)
+}
+
+func (s *Server) ResetWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes = nil
+}
+
+// newID — следующий .id таблицы path: у каждой таблицы своя нумерация,
+// поэтому, например, соединения IPv4 и IPv6 получают одинаковые .id.
+func (s *Server) newID(path string) string {
+	s.nextID[path]++
+	return "*" + strings.ToUpper(strconv.FormatInt(int64(s.nextID[path]), 16))
+}
+
+func cloneRow(r map[string]string) map[string]string {
+	mm := make(map[string]string, len(r))
+	for k, v := range r {
+		mm[k] = v
+	}
+	return mm
+}
+
+// request — разобранное предложение клиента.
+type request struct {
+	cmd   string
+	tag   string
+	args  map[string]string
+	query []string // слова "?..."
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := proto.NewWriter(conn)
+	loggedIn := false
+
+	// открытые print follow этой сессии: тег -> остановка
+	follows := map[string]func(){}
+	defer func() {
+		for _, stop := range follows {
+			stop()
+		}
+	}()
+
+	for {
+		req, err := readRequest(r)
+		if err != nil {
+			return
+		}
+
+		if req.cmd == "/login" {
+			if s.User != "" && (req.args["name"] != s.User || req.args["password"] != s.Password) {
+				_ = reply(w, "!trap", req.tag, map[string]string{"message": "invalid user name or password (6)"})
+				_ = reply(w, "!done", req.tag, nil)
+				return
+			}
+			loggedIn = true
+			if err := reply(w, "!done", req.tag, nil); err != nil {
+				return
+			}
+			continue
+		}
+		if !loggedIn {
+			_ = reply(w, "!fatal", req.tag, map[string]string{"message": "not logged in"})
+			return
+		}
+
+		if req.cmd == "/cancel" {
+			if stop := follows[req.args["tag"]]; stop != nil {
+				stop()
+				delete(follows, req.args["tag"])
+			}
+		}
+		if isFollow(req) {
+			stop, err := s.follow(w, req)
+			if err != nil {
+				if err := trap(w, req.tag, err.Error()); err != nil {
+					return
+				}
+				continue
+			}
+			follows[req.tag] = stop
+			continue
+		}
+
+		if err := s.handle(w, req); err != nil {
+			return
+		}
+	}
+}
+
+func isFollow(req *request) bool {
+	if !strings.HasSuffix(req.cmd, "/print") {
+		return false
+	}
+	_, follow := req.args["follow"]
+	_, followOnly := req.args["follow-only"]
+	return follow || followOnly
+}
+
+// follow обслуживает print =follow= (сначала все строки) и =follow-only= (только изменения):
+// после каждого изменения таблицы шлёт новые и изменившиеся строки целиком, удалённые —
+// как .id с .dead=true. Остановка завершает команду, как на роутере, !trap category=2.
+func (s *Server) follow(w proto.Writer, req *request) (func(), error) {
+	path := strings.TrimSuffix(req.cmd, "/print")
+	_, sendAll := req.args["follow"]
+
+	notify := make(chan struct{}, 1)
+	s.mu.Lock()
+	rows, ok := s.tables[path]
+	if !ok {
+		s.mu.Unlock()
+		return nil, errors.New("no such command prefix")
+	}
+	if s.watchers[path] == nil {
+		s.watchers[path] = map[chan struct{}]struct{}{}
+	}
+	s.watchers[path][notify] = struct{}{}
+	last := indexRows(rows, req.query)
+	s.mu.Unlock()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			s.mu.Lock()
+			delete(s.watchers[path], notify)
+			s.mu.Unlock()
+		}()
+
+		if sendAll {
+			for _, row := range last {
+				if reply(w, "!re", req.tag, row) != nil {
+					return
+				}
+			}
+		}
+		for {
+			select {
+			case <-stop:
+				_ = reply(w, "!trap", req.tag, map[string]string{"category": "2", "message": "interrupted"})
+				_ = reply(w, "!done", req.tag, nil)
+				return
+			case <-notify:
+			}
+
+			s.mu.Lock()
+			cur := indexRows(s.tables[path], req.query)
+			s.mu.Unlock()
+
+			for id, row := range cur {
+				if prev, ok := last[id]; !ok || !maps.Equal(prev, row) {
+					if reply(w, "!re", req.tag, row) != nil {
+						return
+					}
+				}
+			}
+			for id := range last {
+				if _, ok := cur[id]; !ok {
+					if reply(w, "!re", req.tag, map[string]string{".id": id, ".dead": "true"}) != nil {
+						return
+					}
+				}
+			}
+			last = cur
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+		<-done
+	}, nil
+}
+
+// indexRows — копии подходящих под запрос строк по .id.
+func indexRows(rows []map[string]string, query []string) map[string]map[string]string {
+	out := make(map[string]map[string]string, len(rows))
+	for _, r := range rows {
+		if mikrotik.MatchQuery(r, query) {
+			out[r[".id"]] = cloneRow(r)
+		}
+	}
+	return out
+}
+
+// changed будит подписки follow таблицы path; вызывается под s.mu.
+func (s *Server) changed(path string) {
+	for c := range s.watchers[path] {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) handle(w proto.Writer, req *request) error {
+	if req.cmd == "/cancel" {
+		return reply(w, "!done", req.tag, nil)
+	}
+
+	i := strings.LastIndex(req.cmd, "/")
+	if i <= 0 {
+		return trap(w, req.tag, "no such command")
+	}
+	path, verb := req.cmd[:i], req.cmd[i+1:]
+
+	switch verb {
+	case "print", "getall":
+		rows, err := s.print(path, req)
+		if err != nil {
+			return trap(w, req.tag, err.Error())
+		}
+		for _, row := range rows {
+			if err := reply(w, "!re", req.tag, row); err != nil {
+				return err
+			}
+		}
+		return reply(w, "!done", req.tag, nil)
+
+	case "add":
+		id, err := s.add(path, req.args)
+		if err != nil {
+			return trap(w, req.tag, err.Error())
+		}
+		return reply(w, "!done", req.tag, map[string]string{"ret": id})
+
+	case "set":
+		if err := s.set(path, req.args); err != nil {
+			return trap(w, req.tag, err.Error())
+		}
+		return reply(w, "!done", req.tag, nil)
+
+	case "remove":
+		if err := s.remove(path, req.args); err != nil {
+			return trap(w, req.tag, err.Error())
+		}
+		return reply(w, "!done", req.tag, nil)
+	}
+	return trap(w, req.tag, "no such command")
+}
+
+func (s *Server) print(path string, req *request) ([]map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, ok := s.tables[path]
+	if !ok {
+		return nil, errors.New("no such command prefix")
+	}
+
+	var props []string
+	if p := req.args[".proplist"]; p != "" {
+		props = strings.Split(p, ",")
+	}
+
+	out := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		if !mikrotik.MatchQuery(row, req.query) {
+			continue
+		}
+		if props == nil {
+			out = append(out, cloneRow(row))
+			continue
+		}
+		mm := make(map[string]string, len(props))
+		for _, k := range props {
+			if v, ok := row[k]; ok {
+				mm[k] = v
+			}
+		}
+		out = append(out, mm)
+	}
+	return out, nil
+}
+
+func (s *Server) add(path string, args map[string]string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tables[path]; !ok {
+		return "", errors.New("no such command prefix")
+	}
+
+	row := map[string]string{"disabled": "false", "dynamic": "false"}
+	for k, v := range args {
+		row[k] = mikrotik.NormalizeValue(k, v)
+	}
+	if path == "/ip/firewall/address-list" || path == "/ipv6/firewall/address-list" {
+		for _, r := range s.tables[path] {
+			if r["list"] == row["list"] && r["address"] == row["address"] {
+				return "", errors.New("failure: already have such entry")
+			}
+		}
+		row["creation-time"] = time.Now().Format("2006-01-02 15:04:05")
+		// как в RouterOS: запись с timeout — динамическая (не сохраняется в конфигурации)
+		if row["timeout"] != "" {
+			row["dynamic"] = "true"
+		}
+	}
+	id := s.newID(path)
+	row[".id"] = id
+	s.tables[path] = append(s.tables[path], row)
+	s.changed(path)
+	s.record(path+"/add", args)
+	return id, nil
+}
+
+func (s *Server) set(path string, args map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row := s.find(path, args[".id"])
+	if row == nil {
+		return errors.New("no such item")
+	}
+	for k, v := range args {
+		if k == ".id" {
+			continue
+		}
+		row[k] = mikrotik.NormalizeValue(k, v)
+	}
+	if args["timeout"] != "" && strings.HasSuffix(path, "/firewall/address-list") {
+		row["dynamic"] = "true"
+	}
+	s.changed(path)
+	s.record(path+"/set", args)
+	return nil
+}
+
+func (s *Server) remove(path string, args map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := strings.Split(args[".id"], ",")
+	for _, id := range ids {
+		if s.find(path, id) == nil {
+			return errors.New("no such item")
+		}
+	}
+	drop := map[string]bool{}
+	for _, id := range ids {
+		drop[id] = true
+	}
+	rows := s.tables[path][:0]
+	for _, r := range s.tables[path] {
+		if !drop[r[".id"]] {
+			rows = append(rows, r)
+		}
+	}
+	s.tables[path] = rows
+	s.changed(path)
+	s.record(path+"/remove", args)
+	return nil
+}
+
+func (s *Server) find(path, id string) map[string]string {
+	for _, r := range s.tables[path] {
+		if r[".id"] == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *Server) record(cmd string, args map[string]string) {
+	s.writes = append(s.writes, Command{Path: cmd, Args: cloneRow(args), At: time.Now()})
+}
+
+func reply(w proto.Writer, word, tag string, attrs map[string]string) error {
+	w.BeginSentence()
+	w.WriteWord(word)
+
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.WriteWord("=" + k + "=" + attrs[k])
+	}
+	if tag != "" {
+		w.WriteWord(".tag=" + tag)
+	}
+	return w.EndSentence()
+}
+
+func trap(w proto.Writer, tag, msg string) error {
+	if err := reply(w, "!trap", tag, map[string]string{"message": msg}); err != nil {
+		return err
+	}
+	return reply(w, "!done", tag, nil)
+}
+
+func readRequest(r *bufio.Reader) (*request, error) {
+	req := &request{args: map[string]string{}}
+	for {
+		word, err := readWord(r)
+		if err != nil {
+			return nil, err
+		}
+		if word == "" {
+			if req.cmd == "" {
+				continue // пустое предложение
+			}
+			return req, nil
+		}
+
+		switch {
+		case req.cmd == "":
+			req.cmd = word
+		case strings.HasPrefix(word, ".tag="):
+			req.tag = word[len(".tag="):]
+		case strings.HasPrefix(word, "="):
+			k, v, _ := strings.Cut(word[1:], "=")
+			req.args[k] = v
+		case strings.HasPrefix(word, "?"):
+			req.query = append(req.query, word)
+		default:
+			return nil, fmt.Errorf("invalid word %q", word)
+		}
+	}
+}
+
+func readWord(r *bufio.Reader) (string, error) {
+	n, err := readLength(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readLength — кодирование длины слова RouterOS API (1–5 байт).
+func readLength(r *bufio.Reader) (int64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var extra int
+	l := int64(first)
+	switch {
+	case first&0x80 == 0x00:
+		return l, nil
+	case first&0xC0 == 0x80:
+		extra, l = 1, l&^0xC0
+	case first&0xE0 == 0xC0:
+		extra, l = 2, l&^0xE0
+	case first&0xF0 == 0xE0:
+		extra, l = 3, l&^0xF0
+	case first&0xF8 == 0xF0:
+		extra, l = 4, 0
+	default:
+		return 0, fmt.Errorf("invalid length prefix 0x%02x", first)
+	}
+
+	for range extra {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		l = l<<8 | int64(b)
+	}
+	return l, nil
+}
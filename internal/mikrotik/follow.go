@@ -0,0 +1,269 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultResync — период полной пересинхронизации зеркал в потоковом режиме.
+const DefaultResync = 5 * time.Minute
+
+// followQueue — буфер событий listen в библиотеке; читатель зеркала его постоянно разбирает.
+const followQueue = 4096
+
+// StreamPaths — таблицы, которые нужны сбору: DNS-кэш и соединения (при ipv6 — обоих семейств).
+func StreamPaths(ipv6 bool) []string {
+	paths := []string{"/ip/dns/cache/all", IPv4.path("/firewall/connection")}
+	if ipv6 {
+		paths = append(paths, IPv6.path("/firewall/connection"))
+	}
+	return paths
+}
+
+// mirror — копия таблицы роутера в памяти, которую держит print follow-only.
+// Строки после вставки не меняются (обновление заменяет map целиком),
+// поэтому rows отдаёт их без копирования.
+type mirror struct {
+	mu       sync.RWMutex
+	rows     map[string]map[string]string // .id -> строка
+	synced   bool                         // полный print загружен и подписка жива
+	syncedAt time.Time
+
+	// пока идёт полный print, события копятся в pending и накладываются на его результат в reset
+	loading bool
+	pending []map[string]string
+}
+
+func (t *mirror) snapshot() ([]map[string]string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.synced {
+		return nil, false
+	}
+	out := make([]map[string]string, 0, len(t.rows))
+	for _, r := range t.rows {
+		out = append(out, r)
+	}
+	return out, true
+}
+
+// beginLoad вызывается перед полным print: с этого момента события запоминаются для reset.
+func (t *mirror) beginLoad() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loading = true
+	t.pending = nil
+}
+
+// reset заменяет содержимое результатом полного print и повторяет поверх него
+// события, пришедшие, пока print выполнялся, — иначе они бы пропали до следующего resync.
+// Повтор безопасен: события идут по порядку, и наложение уже учтённого изменения ничего не меняет.
+func (t *mirror) reset(rows []map[string]string) {
+	next := make(map[string]map[string]string, len(rows))
+	for _, r := range rows {
+		if id := r[".id"]; id != "" {
+			next[id] = r
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ev := range t.pending {
+		applyEvent(next, ev)
+	}
+	t.rows = next
+	t.loading = false
+	t.pending = nil
+	t.synced = true
+	t.syncedAt = time.Now()
+}
+
+// apply применяет событие follow: .dead — удаление, иначе новые значения полей.
+func (t *mirror) apply(ev map[string]string) {
+	if ev[".id"] == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loading {
+		t.pending = append(t.pending, ev)
+	}
+	if t.rows == nil {
+		t.rows = map[string]map[string]string{}
+	}
+	applyEvent(t.rows, ev)
+}
+
+func applyEvent(rows map[string]map[string]string, ev map[string]string) {
+	id := ev[".id"]
+	if d := ev[".dead"]; d == "true" || d == "yes" {
+		delete(rows, id)
+		return
+	}
+	// follow может прислать только изменившиеся поля — накладываем на прежнюю строку
+	row := make(map[string]string, len(rows[id])+len(ev))
+	for k, v := range rows[id] {
+		row[k] = v
+	}
+	for k, v := range ev {
+		if k != ".dead" {
+			row[k] = v
+		}
+	}
+	rows[id] = row
+}
+
+func (t *mirror) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.synced = false
+	t.rows = nil
+	t.loading = false
+	t.pending = nil
+}
+
+// Follow включает потоковый режим: таблицы paths (меню без команды, например
+// "/ip/firewall/connection") держатся в памяти через print =follow-only= на отдельном
+// подключении, а чтения DNSCache и FirewallConnections отдают зеркало вместо полного print.
+// Раз в resync зеркала перечитываются целиком; пока подписка не поднята (обрыв,
+// переподключение), чтения идут обычным print. Работает до отмены ctx.
+func (m *Client) Follow(ctx context.Context, paths []string, resync time.Duration) {
+	if resync <= 0 {
+		resync = DefaultResync
+	}
+
+	mirrors := make(map[string]*mirror, len(paths))
+	for _, p := range paths {
+		mirrors[p] = &mirror{}
+	}
+	m.followMu.Lock()
+	m.mirrors = mirrors
+	m.followMu.Unlock()
+
+	go func() {
+		failures := 0
+		for ctx.Err() == nil {
+			synced, err := m.followSession(ctx, mirrors, resync)
+			for _, t := range mirrors {
+				t.invalidate()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if synced {
+				failures = 0 // сессия работала — паузу начинаем с минимальной
+			}
+			failures++
+			wait := backoff(failures)
+			log.Printf("mikrotik %s: follow stopped: %v; retry in %s", m.addr, err, wait.Round(time.Second))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
+
+// followSession поднимает отдельное подключение и подписки follow-only на все таблицы
+// и держит их до ошибки; synced — зеркала успели загрузиться хотя бы раз.
+func (m *Client) followSession(ctx context.Context, mirrors map[string]*mirror, resync time.Duration) (synced bool, err error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	c, _, err := m.dial(dialCtx)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	c.Queue = followQueue
+	errC := make(chan error, len(mirrors))
+
+	// сначала подписки, потом полный print: события, пришедшие во время print,
+	// копятся в зеркале и повторяются поверх его результата (см. mirror.reset)
+	for path, t := range mirrors {
+		l, err := c.ListenArgsContext(ctx, []string{path + "/print", "=follow-only="})
+		if err != nil {
+			return false, fmt.Errorf("listen %s: %w", path, err)
+		}
+		go func() {
+			for sen := range l.Chan() {
+				t.apply(sen.Map)
+			}
+			err := l.Err()
+			if err == nil {
+				err = fmt.Errorf("listen %s: stream closed by router", path)
+			}
+			errC <- err
+		}()
+	}
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+
+	for {
+		for path, t := range mirrors {
+			t.beginLoad()
+			rctx, cancel := context.WithTimeout(ctx, time.Minute)
+			r, err := c.RunContext(rctx, path+"/print")
+			cancel()
+			if err != nil {
+				return synced, fmt.Errorf("resync %s: %w", path, err)
+			}
+			t.reset(replyToMaps(r))
+		}
+		synced = true
+
+		select {
+		case <-ctx.Done():
+			return synced, ctx.Err()
+		case err := <-errC:
+			return synced, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// mirrored — строки зеркала path, если потоковый режим включён и синхронизирован.
+func (m *Client) mirrored(path string) ([]map[string]string, bool) {
+	m.followMu.Lock()
+	t := m.mirrors[path]
+	m.followMu.Unlock()
+
+	if t == nil {
+		return nil, false
+	}
+	return t.snapshot()
+}
+
+// streamState — включён ли потоковый режим и когда зеркала в последний раз перечитаны целиком
+// (самое старое из зеркал; нулевое время — не синхронизированы).
+func (m *Client) streamState() (bool, time.Time) {
+	m.followMu.Lock()
+	defer m.followMu.Unlock()
+
+	if m.mirrors == nil {
+		return false, time.Time{}
+	}
+	var oldest time.Time
+	for _, t := range m.mirrors {
+		t.mu.RLock()
+		synced, at := t.synced, t.syncedAt
+		t.mu.RUnlock()
+		if !synced {
+			return true, time.Time{}
+		}
+		if oldest.IsZero() || at.Before(oldest) {
+			oldest = at
+		}
+	}
+	return true, oldest
+}
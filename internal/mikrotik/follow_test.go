@@ -0,0 +1,110 @@
+package mikrotik_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/mikrotik/fake"
+)
+
+const dnsPath = "/ip/dns/cache/all"
+
+func startFake(t *testing.T) *fake.Server {
+	t.Helper()
+	srv := fake.New()
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// mirrorNames — имена в зеркале DNS-кэша через запятую; "" и false — зеркало не синхронизировано.
+func mirrorNames(c *mikrotik.Client) (string, bool) {
+	rows, ok := c.Mirrored(dnsPath)
+	if !ok {
+		return "", false
+	}
+	var names []string
+	for _, r := range rows {
+		names = append(names, r["name"]+"="+r["data"])
+	}
+	sort.Strings(names)
+	return strings.Join(names, ","), true
+}
+
+func mirrorIs(c *mikrotik.Client, want string) func() bool {
+	return func() bool {
+		got, ok := mirrorNames(c)
+		return ok && got == want
+	}
+}
+
+func TestFollowMirror(t *testing.T) {
+	srv := startFake(t)
+	srv.SetTable(dnsPath, []map[string]string{
+		{"name": "a.example", "data": "10.0.0.1"},
+		{"name": "b.example", "data": "10.0.0.2"},
+	})
+	srv.SetTable("/ip/firewall/connection", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := mikrotik.New(srv.Addr(), "admin", "")
+	defer c.Close()
+	c.Follow(ctx, mikrotik.StreamPaths(false), time.Hour)
+
+	eventually(t, "initial sync", mirrorIs(c, "a.example=10.0.0.1,b.example=10.0.0.2"))
+	if st := c.State(); !st.Streaming || st.StreamSyncedAt.IsZero() {
+		t.Fatalf("state = %+v, want streaming and synced", st)
+	}
+
+	// изменение, добавление и удаление (.dead) приходят подпиской без полного print
+	srv.Update(dnsPath, func(rows []map[string]string) []map[string]string {
+		rows[0]["data"] = "10.0.0.11"
+		return append(rows[:1], map[string]string{"name": "c.example", "data": "10.0.0.3"})
+	})
+	eventually(t, "table updates", mirrorIs(c, "a.example=10.0.0.11,c.example=10.0.0.3"))
+
+	// обрыв: зеркало перечитывается целиком и подписка поднимается заново
+	srv.DropConnections()
+	srv.Update(dnsPath, func(rows []map[string]string) []map[string]string {
+		return rows[1:]
+	})
+	eventually(t, "resync after drop", mirrorIs(c, "c.example=10.0.0.3"))
+	srv.Update(dnsPath, func(rows []map[string]string) []map[string]string {
+		return append(rows, map[string]string{"name": "d.example", "data": "10.0.0.4"})
+	})
+	eventually(t, "updates after resubscribe", mirrorIs(c, "c.example=10.0.0.3,d.example=10.0.0.4"))
+
+	// чтение отдаёт зеркало с фильтром на нашей стороне
+	rows, err := c.DNSCache(ctx, mikrotik.Query{Filter: []string{"?name=d.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["data"] != "10.0.0.4" {
+		t.Fatalf("DNSCache = %v", rows)
+	}
+
+	// отмена снимает подписки (/cancel) и выключает зеркала
+	cancel()
+	eventually(t, "mirror off after cancel", func() bool {
+		_, ok := c.Mirrored(dnsPath)
+		return !ok
+	})
+}
@@ -0,0 +1,83 @@
+package mikrotik
+
+import (
+	"reflect"
+	"testing"
+)
+
+// События, пришедшие во время полного print, накладываются на его результат в reset.
+func TestMirrorPendingReplay(t *testing.T) {
+	row := func(id, addr string) map[string]string { return map[string]string{".id": id, "address": addr} }
+	for _, tc := range []struct {
+		name   string
+		before []map[string]string // строки зеркала до полного print
+		events []map[string]string // события во время print
+		print  []map[string]string // результат print
+		want   map[string]map[string]string
+	}{
+		{
+			name:   "print missed the events",
+			events: []map[string]string{row("*1", "10.0.0.2"), {".id": "*2", ".dead": "true"}, row("*3", "10.0.0.3")},
+			print:  []map[string]string{row("*1", "10.0.0.1"), row("*2", "10.0.0.9")},
+			want:   map[string]map[string]string{"*1": row("*1", "10.0.0.2"), "*3": row("*3", "10.0.0.3")},
+		},
+		{
+			name:   "print already has the events",
+			events: []map[string]string{row("*1", "10.0.0.2"), {".id": "*2", ".dead": "true"}},
+			print:  []map[string]string{row("*1", "10.0.0.2")},
+			want:   map[string]map[string]string{"*1": row("*1", "10.0.0.2")},
+		},
+		{
+			name:   "partial update keeps other fields",
+			events: []map[string]string{{".id": "*1", "timeout": "5s"}},
+			print:  []map[string]string{row("*1", "10.0.0.1")},
+			want:   map[string]map[string]string{"*1": {".id": "*1", "address": "10.0.0.1", "timeout": "5s"}},
+		},
+		{
+			name:   "print replaces stale rows",
+			before: []map[string]string{row("*7", "10.0.0.7")},
+			print:  []map[string]string{row("*1", "10.0.0.1")},
+			want:   map[string]map[string]string{"*1": row("*1", "10.0.0.1")},
+		},
+	} {
+		var m mirror
+		m.reset(tc.before)
+		m.beginLoad()
+		for _, ev := range tc.events {
+			m.apply(ev)
+		}
+		m.reset(tc.print)
+
+		rows, ok := m.snapshot()
+		if !ok {
+			t.Fatalf("%s: mirror not synced", tc.name)
+		}
+		got := map[string]map[string]string{}
+		for _, r := range rows {
+			got[r[".id"]] = r
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// События вне загрузки применяются сразу и не копятся.
+func TestMirrorApplyOutsideLoad(t *testing.T) {
+	var m mirror
+	m.reset([]map[string]string{{".id": "*1", "address": "10.0.0.1"}})
+	m.apply(map[string]string{".id": "*1", ".dead": "true"})
+	m.apply(map[string]string{".id": "*2", "address": "10.0.0.2"})
+	if len(m.pending) != 0 {
+		t.Fatalf("pending = %v, want empty", m.pending)
+	}
+	rows, _ := m.snapshot()
+	if len(rows) != 1 || rows[0][".id"] != "*2" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	m.invalidate()
+	if _, ok := m.snapshot(); ok {
+		t.Fatal("invalidated mirror still synced")
+	}
+}
@@ -0,0 +1,124 @@
+package mikrotik
+
+import "strings"
+
+// Query — что запросить у роутера: только нужные поля (.proplist) и слова запроса API
+// для фильтра на стороне роутера ("?src-address=...", "?>key=value", "?#|" и т.п.).
+// Пустой Query — все строки целиком.
+type Query struct {
+	Props  []string
+	Filter []string
+}
+
+// words — Query как слова команды бинарного API.
+func (q Query) words() []string {
+	var out []string
+	if len(q.Props) > 0 {
+		out = append(out, "=.proplist="+strings.Join(q.Props, ","))
+	}
+	return append(out, q.Filter...)
+}
+
+// restBody — Query как тело POST .../print REST API: {".proplist": [...], ".query": [...]}
+// (слова запроса там без ведущего "?").
+func (q Query) restBody() map[string]any {
+	body := map[string]any{}
+	if len(q.Props) > 0 {
+		body[".proplist"] = q.Props
+	}
+	if len(q.Filter) > 0 {
+		words := make([]string, 0, len(q.Filter))
+		for _, w := range q.Filter {
+			words = append(words, strings.TrimPrefix(w, "?"))
+		}
+		body[".query"] = words
+	}
+	return body
+}
+
+func (q Query) empty() bool { return len(q.Props) == 0 && len(q.Filter) == 0 }
+
+// MatchQuery проверяет строку по словам запроса так, как это делает print на роутере:
+// ?key=value, ?key (есть поле), ?-key (нет поля), ?>key=value и ?<key=value (значения
+// сравниваются как строки) и операции над стеком результатов ?#|, ?#&, ?#! (без индексов
+// вида ?#3). Что осталось в стеке, объединяется через И.
+func MatchQuery(row map[string]string, words []string) bool {
+	var stack []bool
+	for _, w := range words {
+		q := strings.TrimPrefix(w, "?")
+		if ops, ok := strings.CutPrefix(q, "#"); ok {
+			for _, op := range ops {
+				n := len(stack)
+				switch {
+				case op == '!' && n >= 1:
+					stack[n-1] = !stack[n-1]
+				case op == '&' && n >= 2:
+					stack = append(stack[:n-2], stack[n-2] && stack[n-1])
+				case op == '|' && n >= 2:
+					stack = append(stack[:n-2], stack[n-2] || stack[n-1])
+				}
+			}
+			continue
+		}
+		stack = append(stack, matchWord(row, q))
+	}
+	for _, ok := range stack {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func matchWord(row map[string]string, q string) bool {
+	switch {
+	case strings.HasPrefix(q, "-"):
+		_, ok := row[q[1:]]
+		return !ok
+	case strings.HasPrefix(q, ">"), strings.HasPrefix(q, "<"):
+		k, v, _ := strings.Cut(q[1:], "=")
+		got, ok := row[k]
+		if !ok {
+			return false
+		}
+		if q[0] == '>' {
+			return got > v
+		}
+		return got < v
+	default:
+		k, v, hasValue := strings.Cut(q, "=")
+		got, ok := row[k]
+		if !ok {
+			return false
+		}
+		return !hasValue || got == NormalizeValue(k, v)
+	}
+}
+
+// NormalizeValue: RouterOS принимает yes/no, а в print отдаёт true/false.
+func NormalizeValue(key, v string) string {
+	switch key {
+	case "disabled", "dynamic", "invalid":
+		switch v {
+		case "yes":
+			return "true"
+		case "no":
+			return "false"
+		}
+	}
+	return v
+}
+
+// filterRows — строки, подходящие под фильтр (для зеркал потокового режима).
+func filterRows(rows []map[string]string, words []string) []map[string]string {
+	if len(words) == 0 {
+		return rows
+	}
+	out := make([]map[string]string, 0, len(rows))
+	for _, r := range rows {
+		if MatchQuery(r, words) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package mikrotik_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-routeros/routeros/v3"
+
+	"mikrotik-parser-go/internal/mikrotik"
+)
+
+// print после обрыва сессии повторяется на новом подключении в том же вызове.
+func TestPrintRetriedAfterDrop(t *testing.T) {
+	ctx := context.Background()
+	srv := startFake(t)
+	srv.SetTable("/ip/dhcp-server/lease", []map[string]string{{"address": "192.168.88.10"}})
+
+	c := mikrotik.New(srv.Addr(), "admin", "")
+	defer c.Close()
+	if _, err := c.DHCPLeases(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+	rows, err := c.DHCPLeases(ctx)
+	if err != nil {
+		t.Fatalf("print after drop: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	if st := c.State(); !st.Connected || st.Reconnects != 1 {
+		t.Fatalf("state = %+v, want connected after 1 reconnect", st)
+	}
+}
+
+// Запись после обрыва не повторяется: неизвестно, дошла ли она до роутера.
+func TestWriteNotRetriedAfterDrop(t *testing.T) {
+	ctx := context.Background()
+	srv := startFake(t)
+	srv.SetTable("/ip/firewall/address-list", nil)
+
+	c := mikrotik.New(srv.Addr(), "admin", "")
+	defer c.Close()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+	if err := c.AddressListAdd(ctx, mikrotik.IPv4, "vpn", "10.0.0.1", mikrotik.EntryOptions{}); err == nil {
+		t.Fatal("add on a dropped session succeeded")
+	}
+	if w := srv.Writes(); len(w) != 0 {
+		t.Fatalf("writes = %v, want none", w)
+	}
+}
+
+// Ответ роутера !trap — не обрыв: сессия остаётся, команда не повторяется
+// (повтор шёл бы только после закрытия сессии и переподключения).
+func TestDeviceErrorNotRetried(t *testing.T) {
+	ctx := context.Background()
+	srv := startFake(t)
+	srv.SetTable("/ip/firewall/address-list", []map[string]string{{"list": "vpn", "address": "10.0.0.1"}})
+
+	c := mikrotik.New(srv.Addr(), "admin", "")
+	defer c.Close()
+
+	err := c.AddressListAdd(ctx, mikrotik.IPv4, "vpn", "10.0.0.1", mikrotik.EntryOptions{})
+	var de *routeros.DeviceError
+	if !errors.As(err, &de) {
+		t.Fatalf("duplicate add = %v, want DeviceError", err)
+	}
+
+	// print по несуществующему меню — тоже !trap
+	if _, err := c.DHCPLeases(ctx); !errors.As(err, &de) {
+		t.Fatalf("print = %v, want DeviceError", err)
+	}
+	if st := c.State(); !st.Connected || st.Reconnects != 0 || st.LastError != "" {
+		t.Fatalf("state = %+v, want the session kept", st)
+	}
+}
@@ -0,0 +1,256 @@
+package mikrotik
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RestClient работает через REST API RouterOS 7 (сервис www-ssl, /rest/...).
+// Формат ответов тот же, что у Client: строки-атрибуты RouterOS как map[string]string.
+type RestClient struct {
+	base string // https://host[:port]/rest
+	user string
+	pass string
+	http *http.Client
+
+	state connState
+}
+
+// NewRest: addr — host[:port] (https по умолчанию) или полный URL вида https://host:port.
+func NewRest(addr, user, pass string, tlsConfig *tls.Config) *RestClient {
+	base := strings.TrimRight(addr, "/")
+	if base != "" && !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	if base != "" && !strings.HasSuffix(base, "/rest") {
+		base += "/rest"
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+
+	return &RestClient{
+		base: base,
+		user: user,
+		pass: pass,
+		http: &http.Client{Transport: tr, Timeout: 30 * time.Second},
+	}
+}
+
+func (m *RestClient) State() State {
+	return m.state.snapshot(m.base, strings.HasPrefix(m.base, "https://"))
+}
+
+func (m *RestClient) Close() error {
+	m.http.CloseIdleConnections()
+	return nil
+}
+
+// RestError — ответ роутера с кодом ошибки (тело вида {"error":400,"message":"...","detail":"..."}).
+type RestError struct {
+	Status  int
+	Message string
+	Detail  string
+}
+
+func (e *RestError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("from RouterOS REST: %d %s: %s", e.Status, e.Message, e.Detail)
+	}
+	return fmt.Sprintf("from RouterOS REST: %d %s", e.Status, e.Message)
+}
+
+func (m *RestClient) do(ctx context.Context, method, path string, query url.Values, body any) ([]byte, error) {
+	if m.base == "" {
+		return nil, errors.New("mikrotik addr is empty")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 6*time.Second)
+		defer cancel()
+	}
+	if err := m.state.checkBackoff(); err != nil {
+		return nil, err
+	}
+
+	u := m.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(m.user, m.pass)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := m.http.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			m.state.dialFailed(err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		m.state.dialFailed(err)
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		err := &RestError{Status: resp.StatusCode, Message: "Unauthorized"}
+		m.state.dialFailed(err)
+		return nil, err
+	}
+	m.state.ensureUp()
+
+	if resp.StatusCode >= 300 {
+		re := &RestError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var body struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		}
+		if json.Unmarshal(data, &body) == nil && body.Message != "" {
+			re.Message, re.Detail = body.Message, body.Detail
+		}
+		return nil, re
+	}
+	return data, nil
+}
+
+func (m *RestClient) print(ctx context.Context, path string, query url.Values) ([]map[string]string, error) {
+	data, err := m.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRestRows(data)
+}
+
+// printQuery — POST .../print с .proplist и .query; пустой Query — обычный GET.
+func (m *RestClient) printQuery(ctx context.Context, path string, q Query) ([]map[string]string, error) {
+	if q.empty() {
+		return m.print(ctx, path, nil)
+	}
+	data, err := m.do(ctx, http.MethodPost, path+"/print", nil, q.restBody())
+	if err != nil {
+		return nil, err
+	}
+	return decodeRestRows(data)
+}
+
+// decodeRestRows приводит ответ REST (массив или одиночный объект) к строковым map,
+// как у бинарного API: REST может вернуть числа и bool не строками.
+func decodeRestRows(data []byte) ([]map[string]string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var raw []map[string]any
+	if data[0] == '{' {
+		var one map[string]any
+		if err := json.Unmarshal(data, &one); err != nil {
+			return nil, err
+		}
+		raw = []map[string]any{one}
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	out := make([]map[string]string, 0, len(raw))
+	for _, r := range raw {
+		mm := make(map[string]string, len(r))
+		for k, v := range r {
+			switch t := v.(type) {
+			case string:
+				mm[k] = t
+			case bool:
+				mm[k] = strconv.FormatBool(t)
+			case float64:
+				mm[k] = strconv.FormatFloat(t, 'f', -1, 64)
+			case nil:
+				mm[k] = ""
+			default:
+				b, _ := json.Marshal(t)
+				mm[k] = string(b)
+			}
+		}
+		out = append(out, mm)
+	}
+	return out, nil
+}
+
+func (m *RestClient) DNSCache(ctx context.Context, q Query) ([]map[string]string, error) {
+	return m.printQuery(ctx, "/ip/dns/cache/all", q)
+}
+
+func (m *RestClient) DHCPLeases(ctx context.Context) ([]map[string]string, error) {
+	return m.print(ctx, "/ip/dhcp-server/lease", nil)
+}
+
+func (m *RestClient) DHCPv6Bindings(ctx context.Context) ([]map[string]string, error) {
+	return m.print(ctx, "/ipv6/dhcp-server/binding", nil)
+}
+
+func (m *RestClient) FirewallConnections(ctx context.Context, fam Family, q Query) ([]map[string]string, error) {
+	return m.printQuery(ctx, fam.path("/firewall/connection"), q)
+}
+
+func (m *RestClient) AddressListIgnoreVPN(ctx context.Context, fam Family, listName string) ([]map[string]string, error) {
+	return m.print(ctx, fam.path("/firewall/address-list"), url.Values{"list": {listName}})
+}
+
+func (m *RestClient) AddressListSetDisabled(ctx context.Context, fam Family, id string, disabled bool, opts EntryOptions) error {
+	val := "no"
+	if disabled {
+		val = "yes"
+	}
+	body := opts.props()
+	body["disabled"] = val
+	_, err := m.do(ctx, http.MethodPatch,
+		fam.path("/firewall/address-list/"+id), nil,
+		body,
+	)
+	return err
+}
+
+func (m *RestClient) AddressListAdd(ctx context.Context, fam Family, listName, address string, opts EntryOptions) error {
+	body := opts.props()
+	body["list"] = listName
+	body["address"] = address
+	_, err := m.do(ctx, http.MethodPut,
+		fam.path("/firewall/address-list"), nil,
+		body,
+	)
+	return err
+}
+
+func (m *RestClient) AddressListRemove(ctx context.Context, fam Family, id string) error {
+	_, err := m.do(ctx, http.MethodDelete, fam.path("/firewall/address-list/"+id), nil, nil)
+	return err
+}
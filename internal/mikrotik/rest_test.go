@@ -0,0 +1,137 @@
+package mikrotik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// restCall — запрос, который получил тестовый REST-сервер.
+type restCall struct {
+	Method string
+	Path   string
+	Query  string
+	Body   map[string]any
+}
+
+// newRestServer отвечает reply на каждый запрос и запоминает запросы в calls.
+func newRestServer(t *testing.T, status int, reply string) (*RestClient, *[]restCall) {
+	t.Helper()
+	var calls []restCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "api" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		c := restCall{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery}
+		if b, _ := io.ReadAll(r.Body); len(b) > 0 {
+			if err := json.Unmarshal(b, &c.Body); err != nil {
+				t.Errorf("%s %s: bad json body: %v", r.Method, r.URL.Path, err)
+			}
+		}
+		calls = append(calls, c)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return NewRest(srv.URL, "api", "secret", nil), &calls
+}
+
+func TestDecodeRestRows(t *testing.T) {
+	rows, err := decodeRestRows([]byte(`[{".id":"*1","disabled":false,"bytes":1234,"timeout":null,"tags":["a"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{{".id": "*1", "disabled": "false", "bytes": "1234", "timeout": "", "tags": `["a"]`}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+
+	rows, err = decodeRestRows([]byte(` {"ret":"*2"} `))
+	if err != nil || len(rows) != 1 || rows[0]["ret"] != "*2" {
+		t.Fatalf("single object: rows = %v, err = %v", rows, err)
+	}
+
+	if rows, err = decodeRestRows(nil); err != nil || rows != nil {
+		t.Fatalf("empty body: rows = %v, err = %v", rows, err)
+	}
+	if _, err = decodeRestRows([]byte(`[{`)); err == nil {
+		t.Fatal("broken json accepted")
+	}
+}
+
+func TestRestPrint(t *testing.T) {
+	m, calls := newRestServer(t, 200, `[{".id":"*1","list":"vpn","address":"10.0.0.1","dynamic":false}]`)
+	rows, err := m.AddressListIgnoreVPN(context.Background(), IPv6, "vpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["address"] != "10.0.0.1" || rows[0]["dynamic"] != "false" {
+		t.Fatalf("rows = %v", rows)
+	}
+	want := restCall{Method: "GET", Path: "/rest/ipv6/firewall/address-list", Query: "list=vpn"}
+	if !reflect.DeepEqual((*calls)[0], want) {
+		t.Fatalf("request = %+v, want %+v", (*calls)[0], want)
+	}
+}
+
+func TestRestPrintQuery(t *testing.T) {
+	m, calls := newRestServer(t, 200, `[]`)
+	q := Query{Props: []string{".id", "src-address"}, Filter: []string{"?protocol=tcp"}}
+	if _, err := m.FirewallConnections(context.Background(), IPv4, q); err != nil {
+		t.Fatal(err)
+	}
+	want := restCall{Method: "POST", Path: "/rest/ip/firewall/connection/print", Body: map[string]any{
+		".proplist": []any{".id", "src-address"},
+		".query":    []any{"protocol=tcp"},
+	}}
+	if !reflect.DeepEqual((*calls)[0], want) {
+		t.Fatalf("request = %+v, want %+v", (*calls)[0], want)
+	}
+}
+
+func TestRestWrites(t *testing.T) {
+	m, calls := newRestServer(t, 200, `{}`)
+	ctx := context.Background()
+	empty := ""
+
+	if err := m.AddressListSetDisabled(ctx, IPv4, "*1A", true, EntryOptions{Comment: &empty}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddressListAdd(ctx, IPv6, "vpn", "example.com", EntryOptions{Timeout: 2 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddressListRemove(ctx, IPv4, "*1A"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []restCall{
+		{Method: "PATCH", Path: "/rest/ip/firewall/address-list/*1A", Body: map[string]any{"disabled": "yes", "comment": ""}},
+		{Method: "PUT", Path: "/rest/ipv6/firewall/address-list", Body: map[string]any{"list": "vpn", "address": "example.com", "timeout": "2h"}},
+		{Method: "DELETE", Path: "/rest/ip/firewall/address-list/*1A"},
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("requests = %+v, want %+v", *calls, want)
+	}
+}
+
+func TestRestErrors(t *testing.T) {
+	m, _ := newRestServer(t, 400, `{"error":400,"message":"Bad Request","detail":"failure: already have such entry"}`)
+	err := m.AddressListAdd(context.Background(), IPv4, "vpn", "10.0.0.1", EntryOptions{})
+	var re *RestError
+	if !errors.As(err, &re) || re.Status != 400 || re.Detail != "failure: already have such entry" {
+		t.Fatalf("err = %v, want RestError 400 with detail", err)
+	}
+
+	bad := NewRest(m.base[:len(m.base)-len("/rest")], "api", "wrong", nil)
+	if _, err := bad.DHCPLeases(context.Background()); !errors.As(err, &re) || re.Status != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want RestError 401", err)
+	}
+}
@@ -0,0 +1,155 @@
+package mikrotik
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-routeros/routeros/v3"
+)
+
+const (
+	backoffMin = time.Second
+	backoffMax = time.Minute
+)
+
+// ErrBackoff — подключение не выполнялось: ещё не истекла пауза после неудачной попытки.
+var ErrBackoff = errors.New("mikrotik reconnect backoff")
+
+var errSessionClosed = errors.New("mikrotik session closed")
+
+// State — состояние подключения к роутеру (для API).
+type State struct {
+	Addr        string    `json:"addr"`
+	TLS         bool      `json:"tls"`
+	Connected   bool      `json:"connected"`
+	ConnectedAt time.Time `json:"connectedAt,omitzero"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitzero"`
+	Reconnects  int       `json:"reconnects"`
+	Failures    int       `json:"failures"` // неудачных попыток подряд
+	NextRetryAt time.Time `json:"nextRetryAt,omitzero"`
+
+	// потоковый режим (print follow-only): когда зеркала перечитаны целиком,
+	// нулевое время — зеркала не синхронизированы и чтения идут обычным print
+	Streaming      bool      `json:"streaming,omitempty"`
+	StreamSyncedAt time.Time `json:"streamSyncedAt,omitzero"`
+}
+
+type connState struct {
+	mu sync.Mutex
+
+	up          bool
+	everUp      bool
+	connectedAt time.Time
+	lastErr     error
+	lastErrAt   time.Time
+	reconnects  int
+	failures    int
+	retryAt     time.Time
+}
+
+func (s *connState) checkBackoff() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if wait := time.Until(s.retryAt); wait > 0 {
+		return fmt.Errorf("%w: next attempt in %s, last error: %v", ErrBackoff, wait.Round(time.Second), s.lastErr)
+	}
+	return nil
+}
+
+func (s *connState) connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.everUp {
+		s.reconnects++
+	}
+	s.everUp = true
+	s.up = true
+	s.connectedAt = time.Now()
+	s.failures = 0
+	s.retryAt = time.Time{}
+}
+
+// ensureUp — для клиентов без постоянной сессии (REST): успешный запрос = подключены.
+func (s *connState) ensureUp() {
+	s.mu.Lock()
+	up := s.up
+	s.mu.Unlock()
+	if !up {
+		s.connected()
+	}
+}
+
+func (s *connState) dialFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.up = false
+	s.failures++
+	s.lastErr, s.lastErrAt = err, time.Now()
+	s.retryAt = time.Now().Add(backoff(s.failures))
+}
+
+// disconnected: err == nil — штатное закрытие.
+func (s *connState) disconnected(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.up = false
+	if err != nil {
+		s.lastErr, s.lastErrAt = err, time.Now()
+	}
+}
+
+func (s *connState) snapshot(addr string, tls bool) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := State{
+		Addr:        addr,
+		TLS:         tls,
+		Connected:   s.up,
+		ConnectedAt: s.connectedAt,
+		LastErrorAt: s.lastErrAt,
+		Reconnects:  s.reconnects,
+		Failures:    s.failures,
+		NextRetryAt: s.retryAt,
+	}
+	if s.lastErr != nil {
+		st.LastError = s.lastErr.Error()
+	}
+	return st
+}
+
+// backoff: экспоненциальная пауза с джиттером в диапазоне [d/2, d].
+func backoff(failures int) time.Duration {
+	d := backoffMin
+	for i := 1; i < failures && d < backoffMax; i++ {
+		d *= 2
+	}
+	d = min(d, backoffMax)
+	return d/2 + rand.N(d/2+1)
+}
+
+// isSessionError: ошибка транспорта (EOF, таймаут, сброс), после которой сессию не использовать.
+// Ответы роутера (!trap) сессию не ломают.
+func isSessionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var de *routeros.DeviceError
+	return !errors.As(err, &de)
+}
+
+func isIdempotent(sentence []string) bool {
+	if len(sentence) == 0 {
+		return false
+	}
+	return strings.HasSuffix(sentence[0], "/print") || strings.HasSuffix(sentence[0], "/getall")
+}
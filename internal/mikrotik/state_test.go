@@ -0,0 +1,74 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-routeros/routeros/v3"
+)
+
+func TestIsSessionError(t *testing.T) {
+	trap := &routeros.DeviceError{}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"eof", io.EOF, true},
+		{"timeout", os.ErrDeadlineExceeded, true},
+		{"context", context.DeadlineExceeded, true},
+		{"trap", trap, false},
+		{"wrapped trap", fmt.Errorf("add: %w", trap), false},
+	} {
+		if got := isSessionError(tc.err); got != tc.want {
+			t.Errorf("%s: isSessionError = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for _, tc := range []struct {
+		sentence []string
+		want     bool
+	}{
+		{[]string{"/ip/dns/cache/all/print", "?name=a"}, true},
+		{[]string{"/system/resource/getall"}, true},
+		{[]string{"/ip/firewall/address-list/add", "=list=x"}, false},
+		{[]string{"/ip/firewall/address-list/remove"}, false},
+		{nil, false},
+	} {
+		if got := isIdempotent(tc.sentence); got != tc.want {
+			t.Errorf("isIdempotent(%v) = %v, want %v", tc.sentence, got, tc.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for failures, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: backoffMax} {
+		for range 20 {
+			if d := backoff(failures); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %s, want [%s, %s]", failures, d, max/2, max)
+			}
+		}
+	}
+}
+
+// После неудачного подключения следующая попытка ждёт паузу, а не стучится сразу.
+func TestConnectBackoff(t *testing.T) {
+	m := New("127.0.0.1:1", "admin", "")
+	if err := m.Connect(context.Background()); err == nil || errors.Is(err, ErrBackoff) {
+		t.Fatalf("first Connect = %v, want dial error", err)
+	}
+	if err := m.Connect(context.Background()); !errors.Is(err, ErrBackoff) {
+		t.Fatalf("second Connect = %v, want ErrBackoff", err)
+	}
+	if st := m.State(); st.Connected || st.Failures != 1 || st.NextRetryAt.IsZero() {
+		t.Fatalf("state = %+v", st)
+	}
+}
@@ -0,0 +1,126 @@
+package mikrotik
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrFingerprintMismatch — сертификат роутера не совпал с закреплённым отпечатком.
+var ErrFingerprintMismatch = errors.New("router certificate fingerprint mismatch")
+
+// TLSOptions — настройки API-SSL (обычно порт 8729).
+//
+// Без CAFile/Fingerprint сертификат проверяется системными CA.
+// Fingerprint (SHA-256 сертификата роутера, hex, можно с ":") заменяет проверку
+// цепочки, если CAFile не задан, и дополняет её, если задан.
+// Insecure отключает проверку цепочки (и CAFile) — только для самоподписанных сертификатов
+// в доверенной сети; заданный Fingerprint проверяется и с Insecure.
+type TLSOptions struct {
+	CAFile      string
+	Fingerprint string
+	Insecure    bool
+}
+
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var roots *x509.CertPool
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s: no PEM certificates found", o.CAFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	if o.Fingerprint == "" {
+		cfg.InsecureSkipVerify = o.Insecure
+		return cfg, nil
+	}
+
+	pin, err := parseFingerprint(o.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	// стандартную проверку выключаем и делаем свою: отпечаток + (опционально) цепочка от CAFile
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("router sent no certificate")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), pin) {
+			return fmt.Errorf("%w: got %s", ErrFingerprintMismatch, hex.EncodeToString(sum[:]))
+		}
+		if roots == nil || o.Insecure {
+			return nil
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			c, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, c)
+		}
+		inter := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			inter.AddCert(c)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: inter})
+		return err
+	}
+	return cfg, nil
+}
+
+func parseFingerprint(s string) (string, error) {
+	s = strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.TrimSpace(s))
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid certificate fingerprint %q: want SHA-256 in hex", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// describeTLSError превращает ошибку рукопожатия в понятное сообщение.
+func describeTLSError(addr string, err error) error {
+	var (
+		unknownCA x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+		recErr    tls.RecordHeaderError
+	)
+
+	hint := ""
+	switch {
+	case errors.Is(err, ErrFingerprintMismatch):
+		hint = "router certificate does not match the pinned fingerprint"
+	case errors.As(err, &unknownCA):
+		hint = "router certificate is not signed by a trusted CA (set a CA bundle, pin its fingerprint or enable insecure mode)"
+	case errors.As(err, &hostErr):
+		hint = "router certificate does not match the router address"
+	case errors.As(err, &certErr):
+		hint = "router certificate is invalid or expired"
+	case errors.As(err, &recErr):
+		hint = "router did not answer with TLS (is api-ssl enabled on this port?)"
+	case strings.Contains(err.Error(), "handshake failure"):
+		hint = "handshake rejected (api-ssl needs a certificate assigned in /ip service)"
+	}
+
+	if hint == "" {
+		return fmt.Errorf("mikrotik api-ssl %s: %w", addr, err)
+	}
+	return fmt.Errorf("mikrotik api-ssl %s: TLS handshake failed: %s: %w", addr, hint, err)
+}
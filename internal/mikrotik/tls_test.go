@@ -0,0 +1,45 @@
+package mikrotik
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTLSFingerprintWithInsecure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("00", sha256.Size)
+
+	dial := func(o TLSOptions) error {
+		cfg, err := o.Config()
+		if err != nil {
+			return err
+		}
+		c, err := tls.Dial("tcp", srv.Listener.Addr().String(), cfg)
+		if err == nil {
+			_ = c.Close()
+		}
+		return err
+	}
+
+	if err := dial(TLSOptions{Insecure: true}); err != nil {
+		t.Fatalf("insecure without pin: %v", err)
+	}
+	if err := dial(TLSOptions{Insecure: true, Fingerprint: good}); err != nil {
+		t.Fatalf("insecure with matching pin: %v", err)
+	}
+	if err := dial(TLSOptions{Insecure: true, Fingerprint: bad}); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Fatalf("insecure with wrong pin: err = %v, want %v", err, ErrFingerprintMismatch)
+	}
+	if err := dial(TLSOptions{}); err == nil {
+		t.Fatal("self-signed certificate accepted without CA, pin or insecure")
+	}
+}
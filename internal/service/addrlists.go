@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+)
+
+// addressListTTL — сколько живёт закэшированный address-list, если коллектор его не обновил
+// (коллектор обновляет листы каждый тик, свои записи сбрасывают кэш сразу).
+const addressListTTL = 30 * time.Second
+
+type listKey struct {
+	fam  mikrotik.Family
+	name string
+}
+
+type cachedList struct {
+	rows []map[string]string
+	at   time.Time
+}
+
+// addressListCache — address-листы роутера в памяти, чтобы проверки ignore-состояния
+// не ходили на роутер за каждым доменом. Строки только читаются, не меняются.
+type addressListCache struct {
+	mu    sync.Mutex
+	lists map[listKey]cachedList
+	// gen растёт при каждом invalidate: чтение, начатое до сброса, не должно
+	// положить в кэш лист без записи, которая его сбросила
+	gen uint64
+}
+
+func (c *addressListCache) get(k listKey) (cachedList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.lists[k]
+	if !ok || time.Since(l.at) > addressListTTL {
+		return cachedList{}, false
+	}
+	return l, true
+}
+
+// generation — текущее поколение кэша; берётся до чтения листа с роутера и передаётся в put.
+func (c *addressListCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// put кладёт лист, если с начала его чтения (поколение gen) кэш не сбрасывался.
+func (c *addressListCache) put(k listKey, rows []map[string]string, at time.Time, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.lists == nil {
+		c.lists = map[listKey]cachedList{}
+	}
+	c.lists[k] = cachedList{rows: rows, at: at}
+}
+
+func (c *addressListCache) invalidate(k listKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.lists, k)
+	c.gen++
+}
+
+// addressList возвращает address-list из кэша (или с роутера, если кэш устарел)
+// и время, когда он был прочитан с роутера.
+func (s *ConnectionsService) addressList(ctx context.Context, fam mikrotik.Family, name string) ([]map[string]string, time.Time, error) {
+	k := listKey{fam: fam, name: name}
+	if l, ok := s.lists.get(k); ok {
+		return l.rows, l.at, nil
+	}
+	return s.loadAddressList(ctx, k)
+}
+
+func (s *ConnectionsService) loadAddressList(ctx context.Context, k listKey) ([]map[string]string, time.Time, error) {
+	gen := s.lists.generation()
+	at := time.Now().UTC()
+	rows, err := s.mt.AddressListIgnoreVPN(ctx, k.fam, k.name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	s.lists.put(k, rows, at, gen)
+	return rows, at, nil
+}
+
+// RefreshAddressLists перечитывает с роутера листы ignore-VPN и ignore-lan-to-vpn
+// всех семейств; вызывается коллектором на каждом тике.
+func (s *ConnectionsService) RefreshAddressLists(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	for _, fam := range s.families() {
+		for _, name := range []string{s.ignoreVPNListName, s.ignoreLanToVpnListName} {
+			if _, _, err := s.loadAddressList(ctx, listKey{fam: fam, name: name}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IgnoreVPNUpdatedAt — когда лист ignore-VPN, по которому считается isIgnoreVpn,
+// был прочитан с роутера (нулевое время — ещё не читался).
+func (s *ConnectionsService) IgnoreVPNUpdatedAt() time.Time {
+	s.lists.mu.Lock()
+	defer s.lists.mu.Unlock()
+	return s.lists.lists[listKey{fam: mikrotik.IPv4, name: s.ignoreVPNListName}].at
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+)
+
+// Чтение, начатое до invalidate, не возвращает в кэш устаревший лист.
+func TestAddressListCacheDropsStalePut(t *testing.T) {
+	var c addressListCache
+	k := listKey{fam: mikrotik.IPv4, name: "ignore"}
+
+	gen := c.generation()
+	c.invalidate(k) // запись на роутер, пока шло чтение
+	c.put(k, []map[string]string{{"address": "old"}}, time.Now(), gen)
+	if _, ok := c.get(k); ok {
+		t.Fatal("stale list was cached after invalidate")
+	}
+
+	c.put(k, nil, time.Now(), c.generation())
+	if _, ok := c.get(k); !ok {
+		t.Fatal("fresh list was not cached")
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// RequestOrigin — откуда пришёл запрос на изменение (для журнала).
+type RequestOrigin struct {
+	Addr      string
+	UserAgent string
+}
+
+type originKey struct{}
+
+func WithOrigin(ctx context.Context, o RequestOrigin) context.Context {
+	return context.WithValue(ctx, originKey{}, o)
+}
+
+func originFrom(ctx context.Context) RequestOrigin {
+	o, _ := ctx.Value(originKey{}).(RequestOrigin)
+	return o
+}
+
+// UseAudit включает журнал изменений address-листов.
+func (s *ConnectionsService) UseAudit(repo *storage.Sqlite) { s.audit = repo }
+
+// Audit — журнал изменений этого роутера.
+func (s *ConnectionsService) Audit(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEntry, error) {
+	if s.audit == nil {
+		return nil, nil
+	}
+	f.Router = s.router
+	return s.audit.FindAudit(ctx, f)
+}
+
+// listState — состояние записи address-листа; nil — записи нет.
+func listState(row map[string]string) string {
+	if row == nil {
+		return storage.StateAbsent
+	}
+	if d := strings.ToLower(row["disabled"]); d == "true" || d == "yes" {
+		return storage.StateDisabled
+	}
+	return storage.StateEnabled
+}
+
+// recordChange пишет изменение записи в журнал; автор и источник берутся из контекста.
+// Ошибка записи журнала не отменяет уже сделанное на роутере изменение — только логируется.
+func (s *ConnectionsService) recordChange(ctx context.Context, fam mikrotik.Family, list, address, before, after string, opts mikrotik.EntryOptions, opErr error) {
+	if s.audit == nil {
+		return
+	}
+
+	e := storage.AuditEntry{
+		Router:  s.router,
+		List:    list,
+		Family:  string(fam),
+		Address: address,
+		Before:  before,
+		After:   after,
+		Comment: opts.CommentText(),
+		Result:  "ok",
+	}
+	if opts.Timeout > 0 {
+		e.Timeout = mikrotik.FormatDuration(opts.Timeout)
+	}
+	if p, ok := PrincipalFrom(ctx); ok {
+		e.Actor, e.Via = p.Name, p.Via
+	}
+	o := originFrom(ctx)
+	e.Origin, e.UserAgent = o.Addr, o.UserAgent
+	if opErr != nil {
+		e.Result, e.Error = "error", opErr.Error()
+	}
+
+	// контекст запроса к этому моменту мог истечь (ошибка по таймауту тоже пишется)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	if err := s.audit.InsertAudit(ctx, e); err != nil {
+		log.Println("audit:", err)
+	}
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role — роль пользователя или токена API; роли упорядочены, старшая включает младшие.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // только чтение
+	RoleOperator Role = "operator" // + переключение address-list (ignoreVpn, ignoreLanToVpn)
+	RoleAdmin    Role = "admin"    // + пользователи и токены
+)
+
+var roleLevel = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if roleLevel[r] == 0 {
+		return "", fmt.Errorf("unknown role %q (want viewer, operator or admin)", s)
+	}
+	return r, nil
+}
+
+// Allows: роль r не ниже need.
+func (r Role) Allows(need Role) bool { return roleLevel[r] >= roleLevel[need] }
+
+// minRole — меньшая из двух ролей.
+func minRole(a, b Role) Role {
+	if a.Allows(b) {
+		return b
+	}
+	return a
+}
+
+// Principal — кто выполняет запрос.
+type Principal struct {
+	UserID int64  `json:"userId"`
+	Name   string `json:"name"`
+	Role   Role   `json:"role"`
+	Via    string `json:"via"` // "session", "token" или "" (аутентификация выключена)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom — автор запроса из контекста; ok == false — запрос без аутентификации.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+var (
+	ErrBadCredentials = errors.New("invalid user name or password")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrLastAdmin      = errors.New("cannot demote or delete the last admin")
+)
+
+// tokenPrefix — у токенов API, чтобы их было видно в конфигах и логах.
+const tokenPrefix = "mpt_"
+
+type AuthService struct {
+	repo       *storage.Sqlite
+	sessionTTL time.Duration
+}
+
+func NewAuthService(repo *storage.Sqlite, sessionTTL time.Duration) *AuthService {
+	return &AuthService{repo: repo, sessionTTL: sessionTTL}
+}
+
+// SessionTTL — срок жизни сессии (и cookie).
+func (s *AuthService) SessionTTL() time.Duration { return s.sessionTTL }
+
+// Bootstrap создаёт администратора name/password, если пользователей ещё нет.
+// Возвращает true, если пользователь создан.
+func (s *AuthService) Bootstrap(ctx context.Context, name, password string) (bool, error) {
+	n, err := s.repo.CountUsers(ctx)
+	if err != nil || n > 0 || name == "" || password == "" {
+		return false, err
+	}
+	if _, err := s.CreateUser(ctx, name, password, RoleAdmin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *AuthService) CreateUser(ctx context.Context, name, password string, role Role) (storage.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || password == "" {
+		return storage.User{}, errors.New("name and password are required")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return storage.User{}, err
+	}
+	return s.repo.CreateUser(ctx, name, string(hash), string(role))
+}
+
+// UpdateUser меняет роль и пароль; пустые значения оставляют прежние.
+// Смена пароля закрывает сессии пользователя; последнего администратора понизить нельзя.
+func (s *AuthService) UpdateUser(ctx context.Context, id int64, password string, role Role) error {
+	if role != "" && role != RoleAdmin {
+		if err := s.checkNotLastAdmin(ctx, id); err != nil {
+			return err
+		}
+	}
+	hash := ""
+	if password != "" {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		hash = string(b)
+	}
+	return s.repo.UpdateUser(ctx, id, hash, string(role))
+}
+
+func (s *AuthService) DeleteUser(ctx context.Context, id int64) error {
+	if err := s.checkNotLastAdmin(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteUser(ctx, id)
+}
+
+// checkNotLastAdmin — ErrLastAdmin, если id — единственный администратор.
+func (s *AuthService) checkNotLastAdmin(ctx context.Context, id int64) error {
+	u, err := s.repo.FindUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if Role(u.Role) != RoleAdmin {
+		return nil
+	}
+	n, err := s.repo.CountUsersWithRole(ctx, string(RoleAdmin))
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func (s *AuthService) Users(ctx context.Context) ([]storage.User, error) {
+	return s.repo.ListUsers(ctx)
+}
+
+// Login проверяет пароль и открывает сессию; возвращает идентификатор сессии для cookie.
+func (s *AuthService) Login(ctx context.Context, name, password string) (string, Principal, error) {
+	u, err := s.repo.FindUserByName(ctx, strings.TrimSpace(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		// сравниваем с фиктивным хэшем, чтобы время ответа не выдавало существование пользователя
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return "", Principal{}, ErrBadCredentials
+	}
+	if err != nil {
+		return "", Principal{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return "", Principal{}, ErrBadCredentials
+	}
+
+	id, err := randomToken("")
+	if err != nil {
+		return "", Principal{}, err
+	}
+	if err := s.repo.CreateSession(ctx, hashToken(id), u.ID, time.Now().Add(s.sessionTTL)); err != nil {
+		return "", Principal{}, err
+	}
+	return id, Principal{UserID: u.ID, Name: u.Name, Role: Role(u.Role), Via: "session"}, nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	return s.repo.DeleteSession(ctx, hashToken(sessionID))
+}
+
+// Session — пользователь по идентификатору сессии из cookie.
+func (s *AuthService) Session(ctx context.Context, sessionID string) (Principal, error) {
+	u, err := s.repo.FindSession(ctx, hashToken(sessionID), time.Now())
+	if errors.Is(err, storage.ErrNotFound) {
+		return Principal{}, ErrUnauthorized
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{UserID: u.ID, Name: u.Name, Role: Role(u.Role), Via: "session"}, nil
+}
+
+// Token — владелец и роль токена API.
+func (s *AuthService) Token(ctx context.Context, token string) (Principal, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return Principal{}, ErrUnauthorized
+	}
+	t, err := s.repo.FindToken(ctx, hashToken(token), time.Now())
+	if errors.Is(err, storage.ErrNotFound) {
+		return Principal{}, ErrUnauthorized
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	// роль токена не выше текущей роли владельца: понижение пользователя понижает и его токены
+	return Principal{UserID: t.UserID, Name: t.UserName, Role: minRole(Role(t.Role), Role(t.OwnerRole)), Via: "token"}, nil
+}
+
+// CreateToken выпускает токен для owner с ролью не выше его собственной; значение
+// токена возвращается только здесь. ttl == 0 — бессрочный.
+func (s *AuthService) CreateToken(ctx context.Context, owner Principal, name string, role Role, ttl time.Duration) (string, storage.APIToken, error) {
+	if !owner.Role.Allows(role) {
+		return "", storage.APIToken{}, fmt.Errorf("role %s exceeds owner role %s", role, owner.Role)
+	}
+	token, err := randomToken(tokenPrefix)
+	if err != nil {
+		return "", storage.APIToken{}, err
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	t, err := s.repo.CreateToken(ctx, owner.UserID, strings.TrimSpace(name), hashToken(token), string(role), expires)
+	t.UserName = owner.Name
+	return token, t, err
+}
+
+// Tokens — токены пользователя; администратор видит все.
+func (s *AuthService) Tokens(ctx context.Context, p Principal) ([]storage.APIToken, error) {
+	if p.Role.Allows(RoleAdmin) {
+		return s.repo.ListTokens(ctx, 0)
+	}
+	return s.repo.ListTokens(ctx, p.UserID)
+}
+
+// DeleteToken отзывает токен; не администратор — только свой.
+func (s *AuthService) DeleteToken(ctx context.Context, p Principal, id int64) error {
+	owner := p.UserID
+	if p.Role.Allows(RoleAdmin) {
+		owner = 0
+	}
+	return s.repo.DeleteToken(ctx, id, owner)
+}
+
+func randomToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken — в базе храним только sha256: токены случайные, соль не нужна.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mikrotik-parser-go/internal/storage"
+)
+
+func newTestAuth(t *testing.T) *AuthService {
+	t.Helper()
+	return NewAuthService(openTestDB(t), time.Hour)
+}
+
+func mustUser(t *testing.T, s *AuthService, name string, role Role) storage.User {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), name, name+"-password", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func principalOf(u storage.User) Principal {
+	return Principal{UserID: u.ID, Name: u.Name, Role: Role(u.Role), Via: "session"}
+}
+
+// Роль токена ограничена текущей ролью владельца.
+func TestTokenRoleCappedByOwner(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAuth(t)
+	mustUser(t, s, "root", RoleAdmin)
+	u := mustUser(t, s, "alice", RoleAdmin)
+
+	token, _, err := s.CreateToken(ctx, principalOf(u), "ci", RoleAdmin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		owner Role
+		want  Role
+	}{
+		{RoleAdmin, RoleAdmin},
+		{RoleOperator, RoleOperator},
+		{RoleViewer, RoleViewer},
+	} {
+		if err := s.UpdateUser(ctx, u.ID, "", tc.owner); err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.Token(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Role != tc.want {
+			t.Errorf("owner %s: token role %s, want %s", tc.owner, p.Role, tc.want)
+		}
+	}
+}
+
+func TestCreateTokenRoleLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAuth(t)
+	for _, tc := range []struct {
+		owner, role Role
+		ok          bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleOperator, false},
+		{RoleOperator, RoleAdmin, false},
+		{RoleOperator, RoleViewer, true},
+		{RoleAdmin, RoleAdmin, true},
+	} {
+		u := mustUser(t, s, string(tc.owner)+"-"+string(tc.role), tc.owner)
+		_, _, err := s.CreateToken(ctx, principalOf(u), "t", tc.role, 0)
+		if (err == nil) != tc.ok {
+			t.Errorf("owner %s, token %s: err = %v, want ok=%v", tc.owner, tc.role, err, tc.ok)
+		}
+	}
+}
+
+func TestLastAdmin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAuth(t)
+	admin := mustUser(t, s, "root", RoleAdmin)
+	viewer := mustUser(t, s, "bob", RoleViewer)
+
+	for _, tc := range []struct {
+		name string
+		do   func() error
+		want error
+	}{
+		{"demote only admin", func() error { return s.UpdateUser(ctx, admin.ID, "", RoleOperator) }, ErrLastAdmin},
+		{"delete only admin", func() error { return s.DeleteUser(ctx, admin.ID) }, ErrLastAdmin},
+		{"password of only admin", func() error { return s.UpdateUser(ctx, admin.ID, "new-password", "") }, nil},
+		{"promote viewer", func() error { return s.UpdateUser(ctx, viewer.ID, "", RoleAdmin) }, nil},
+		{"demote one of two admins", func() error { return s.UpdateUser(ctx, admin.ID, "", RoleViewer) }, nil},
+		{"delete last admin again", func() error { return s.DeleteUser(ctx, viewer.ID) }, ErrLastAdmin},
+	} {
+		if err := tc.do(); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// Смена пароля закрывает открытые сессии пользователя.
+func TestPasswordChangeClosesSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAuth(t)
+	mustUser(t, s, "root", RoleAdmin)
+	u := mustUser(t, s, "alice", RoleOperator)
+
+	sid, _, err := s.Login(ctx, "alice", "alice-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(ctx, sid); err != nil {
+		t.Fatalf("session before password change: %v", err)
+	}
+	if err := s.UpdateUser(ctx, u.ID, "changed", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(ctx, sid); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("session after password change: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, _, err := s.Login(ctx, "alice", "alice-password"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("login with old password: err = %v", err)
+	}
+	if _, p, err := s.Login(ctx, "alice", "changed"); err != nil || p.Role != RoleOperator {
+		t.Fatalf("login with new password: p = %+v, err = %v", p, err)
+	}
+}
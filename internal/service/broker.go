@@ -0,0 +1,231 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"mikrotik-parser-go/internal/domain"
+)
+
+// Типы StreamUpdate.
+const (
+	UpdateSnapshot = "snapshot" // всё текущее состояние роутера — первым сообщением подписчику
+	UpdateDelta    = "delta"    // изменения за тик коллектора
+)
+
+// StreamUpdate — сообщение живого потока: открытые/закрытые соединения и изменения
+// числа активных соединений по доменам.
+type StreamUpdate struct {
+	Type   string              `json:"type"`
+	Router string              `json:"router"`
+	At     time.Time           `json:"at"`
+	Opened []domain.Connection `json:"opened"` // у snapshot — все текущие соединения
+	Closed []domain.Connection `json:"closed"`
+	Counts []CountChange       `json:"counts"`
+}
+
+// CountChange — новое число активных соединений домена (0 — соединений больше нет).
+type CountChange struct {
+	DstDNS            string `json:"dstDns"`
+	ActiveConnections int64  `json:"activeConnections"`
+	Prev              int64  `json:"prev"`
+}
+
+// StreamFilter — что подписчик хочет получать; пустые поля не фильтруют.
+type StreamFilter struct {
+	Routers []string // имена роутеров
+	SrcIP   string
+	Domain  string // подстрока домена, без учёта регистра
+}
+
+// apply оставляет в u только подходящее под фильтр; false — отправлять нечего.
+// Счётчики доменов общие для роутера, поэтому srcIp их не фильтрует.
+func (f StreamFilter) apply(u StreamUpdate) (StreamUpdate, bool) {
+	if len(f.Routers) > 0 && !slices.Contains(f.Routers, u.Router) {
+		return u, false
+	}
+	if f.SrcIP == "" && f.Domain == "" {
+		return u, u.Type != UpdateDelta || !u.empty()
+	}
+
+	find := strings.ToLower(f.Domain)
+	match := func(c domain.Connection) bool {
+		if f.SrcIP != "" && c.SrcIP != f.SrcIP {
+			return false
+		}
+		return find == "" || strings.Contains(strings.ToLower(c.DstDNS), find)
+	}
+
+	out := StreamUpdate{Type: u.Type, Router: u.Router, At: u.At}
+	out.Opened = filterConns(u.Opened, match)
+	out.Closed = filterConns(u.Closed, match)
+	out.Counts = []CountChange{}
+	for _, c := range u.Counts {
+		if find == "" || strings.Contains(strings.ToLower(c.DstDNS), find) {
+			out.Counts = append(out.Counts, c)
+		}
+	}
+	return out, u.Type != UpdateDelta || !out.empty()
+}
+
+func (u StreamUpdate) empty() bool {
+	return len(u.Opened) == 0 && len(u.Closed) == 0 && len(u.Counts) == 0
+}
+
+func filterConns(conns []domain.Connection, keep func(domain.Connection) bool) []domain.Connection {
+	out := []domain.Connection{}
+	for _, c := range conns {
+		if keep(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// subscriberBuffer — сколько сообщений ждёт медленного подписчика; при переполнении
+// подписка закрывается (клиент переподключится и получит свежий snapshot).
+const subscriberBuffer = 64
+
+// Subscription — подписка на поток; C закрывается при отписке, переполнении или Close брокера.
+type Subscription struct {
+	C      <-chan StreamUpdate
+	c      chan StreamUpdate
+	filter StreamFilter
+}
+
+// Broker раздаёт обновления коллекторов подписчикам внутри процесса.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	last   map[string]StreamUpdate // последний snapshot по роутеру
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: map[*Subscription]struct{}{},
+		last: map[string]StreamUpdate{},
+	}
+}
+
+// Subscribe подписывает на обновления; первыми приходят текущие snapshot'ы роутеров.
+func (b *Broker) Subscribe(f StreamFilter) *Subscription {
+	f.Domain = strings.TrimSpace(f.Domain)
+	f.SrcIP = strings.TrimSpace(f.SrcIP)
+	if f.SrcIP != "" {
+		f.SrcIP = canonIP(f.SrcIP)
+	}
+
+	c := make(chan StreamUpdate, subscriberBuffer)
+	s := &Subscription{C: c, c: c, filter: f}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return s
+	}
+	for _, snap := range b.last {
+		if u, ok := f.apply(snap); ok && len(c) < cap(c) {
+			c <- u
+		}
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// Publish запоминает snapshot роутера и рассылает delta подписчикам.
+func (b *Broker) Publish(snapshot, delta StreamUpdate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.last[snapshot.Router] = snapshot
+
+	for s := range b.subs {
+		u, ok := s.filter.apply(delta)
+		if !ok {
+			continue
+		}
+		select {
+		case s.c <- u:
+		default:
+			delete(b.subs, s)
+			close(s.c)
+		}
+	}
+}
+
+// Close закрывает все подписки (при остановке сервера).
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// streamTracker помнит соединения и счётчики доменов прошлого тика, чтобы считать delta.
+// Используется только из горутины CollectService.Run.
+type streamTracker struct {
+	conns  map[string]domain.Connection
+	counts map[string]int64
+}
+
+// connKey — ID соединения (семейство и .id conntrack), а без него — протокол и адреса с портами.
+func connKey(c domain.Connection) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.Protocol + "|" + c.SrcIP + ":" + c.SrcPort + "|" + c.DstIP + ":" + c.DstPort
+}
+
+// update возвращает snapshot текущего состояния и delta относительно прошлого тика.
+func (t *streamTracker) update(router string, at time.Time, conns []domain.Connection, counts map[string]int64) (StreamUpdate, StreamUpdate) {
+	snapshot := StreamUpdate{Type: UpdateSnapshot, Router: router, At: at, Opened: conns, Closed: []domain.Connection{}, Counts: []CountChange{}}
+	delta := StreamUpdate{Type: UpdateDelta, Router: router, At: at, Opened: []domain.Connection{}, Closed: []domain.Connection{}, Counts: []CountChange{}}
+
+	next := make(map[string]domain.Connection, len(conns))
+	for _, c := range conns {
+		k := connKey(c)
+		next[k] = c
+		if _, ok := t.conns[k]; !ok {
+			delta.Opened = append(delta.Opened, c)
+		}
+	}
+	for k, c := range t.conns {
+		if _, ok := next[k]; !ok {
+			delta.Closed = append(delta.Closed, c)
+		}
+	}
+
+	for dns, n := range counts {
+		snapshot.Counts = append(snapshot.Counts, CountChange{DstDNS: dns, ActiveConnections: n})
+		if prev := t.counts[dns]; prev != n {
+			delta.Counts = append(delta.Counts, CountChange{DstDNS: dns, ActiveConnections: n, Prev: prev})
+		}
+	}
+	for dns, prev := range t.counts {
+		if _, ok := counts[dns]; !ok {
+			delta.Counts = append(delta.Counts, CountChange{DstDNS: dns, Prev: prev})
+		}
+	}
+
+	t.conns = next
+	t.counts = counts
+	return snapshot, delta
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"mikrotik-parser-go/internal/domain"
 	"mikrotik-parser-go/internal/storage"
 )
 
@@ -13,12 +16,23 @@ type CollectService struct {
 	connections *ConnectionsService
 	repo        *storage.Sqlite
 	interval    time.Duration
+
+	// живой поток; nil — не публиковать
+	broker *Broker
+
+	// соединения последнего тика: ручки читают их, а не делают полный print на каждый запрос
+	lastMu    sync.RWMutex
+	lastConns []domain.Connection
+	lastAt    time.Time
 }
 
 func NewCollectService(connections *ConnectionsService, repo *storage.Sqlite, interval time.Duration) *CollectService {
 	return &CollectService{connections: connections, repo: repo, interval: interval}
 }
 
+// UseBroker включает публикацию снимков и изменений каждого тика в broker.
+func (c *CollectService) UseBroker(b *Broker) { c.broker = b }
+
 func (c *CollectService) Run(ctx context.Context) {
 	t := time.NewTicker(c.interval)
 	defer t.Stop()
@@ -28,6 +42,9 @@ func (c *CollectService) Run(ctx context.Context) {
 		dns string
 	}
 
+	var traffic trafficTracker
+	var stream streamTracker
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -37,13 +54,36 @@ func (c *CollectService) Run(ctx context.Context) {
 			if err != nil {
 				continue
 			}
+			c.lastMu.Lock()
+			c.lastConns, c.lastAt = conns, time.Now()
+			c.lastMu.Unlock()
+
+			// address-листы — в кэш, чтобы ручки не ходили за ними на роутер
+			_ = c.connections.RefreshAddressLists(ctx)
+
+			// история сессий — все соединения, в том числе без домена
+			_ = c.repo.SaveAll(ctx, c.connections.router, conns)
+
+			deltas := traffic.deltas(conns)
 
 			// DNS -> count
-			mDNS := map[string]int64{}
+			mDNS := map[string]*storage.DomainCount{}
 			// (IP,DNS) -> count
-			mDst := map[key]int64{}
+			mDst := map[key]*storage.DstCount{}
+			// src IP -> count; хосты считаем и по соединениям без домена
+			mHost := map[string]*storage.HostCount{}
+
+			for i, cn := range conns {
+				if src := strings.TrimSpace(cn.SrcIP); src != "" {
+					h := mHost[src]
+					if h == nil {
+						h = &storage.HostCount{SrcIP: src}
+						mHost[src] = h
+					}
+					h.Count++
+					h.Add(deltas[i])
+				}
 
-			for _, cn := range conns {
 				dns := strings.TrimSpace(cn.DstDNS)
 				if dns == "" {
 					continue
@@ -52,28 +92,108 @@ func (c *CollectService) Run(ctx context.Context) {
 				if ip == "" {
 					continue
 				}
-				mDNS[dns]++
-				mDst[key{ip: ip, dns: dns}]++
+
+				d := mDNS[dns]
+				if d == nil {
+					d = &storage.DomainCount{DstDNS: dns}
+					mDNS[dns] = d
+				}
+				d.Count++
+				d.Add(deltas[i])
+
+				k := key{ip: ip, dns: dns}
+				dst := mDst[k]
+				if dst == nil {
+					dst = &storage.DstCount{DstIP: ip, DstDNS: dns, CNAMEs: cn.DstCNAMEs, OtherNames: cn.DstOtherNames}
+					mDst[k] = dst
+				}
+				dst.Count++
+				dst.Add(deltas[i])
 			}
 
 			domainCounts := make([]storage.DomainCount, 0, len(mDNS))
-			for dns, cnt := range mDNS {
-				domainCounts = append(domainCounts, storage.DomainCount{DstDNS: dns, Count: cnt})
+			for _, d := range mDNS {
+				domainCounts = append(domainCounts, *d)
 			}
 
 			dstCounts := make([]storage.DstCount, 0, len(mDst))
-			for k, cnt := range mDst {
-				dstCounts = append(dstCounts, storage.DstCount{DstIP: k.ip, DstDNS: k.dns, Count: cnt})
+			for _, d := range mDst {
+				dstCounts = append(dstCounts, *d)
+			}
+
+			hostCounts := make([]storage.HostCount, 0, len(mHost))
+			for _, h := range mHost {
+				hostCounts = append(hostCounts, *h)
 			}
 
-			_ = c.repo.UpsertDomainCounts(ctx, domainCounts)
-			_ = c.repo.UpsertDstCounts(ctx, dstCounts)
+			if c.broker != nil {
+				counts := make(map[string]int64, len(mDNS))
+				for dns, d := range mDNS {
+					counts[dns] = d.Count
+				}
+				c.broker.Publish(stream.update(c.connections.router, time.Now().UTC(), conns, counts))
+			}
+
+			_ = c.repo.UpsertDomainCounts(ctx, c.connections.router, domainCounts)
+			_ = c.repo.UpsertDstCounts(ctx, c.connections.router, dstCounts)
+			_ = c.repo.AddRollupSample(ctx, c.connections.router, time.Now(), domainCounts, dstCounts, hostCounts)
 		}
 	}
 }
 
-func (c *CollectService) GetByDNS(ctx context.Context, name string) ([]map[string]any, error) {
-	rows, err := c.repo.FindDstCountsLike(ctx, name) // читаем dst_conn_counts
+// Connections — соединения последнего тика коллектора. Если тика ещё не было или он
+// старше двух интервалов (роутер недоступен, коллектор не запущен) — читает с роутера.
+// Срез общий: его нельзя менять.
+func (c *CollectService) Connections(ctx context.Context) ([]domain.Connection, error) {
+	c.lastMu.RLock()
+	conns, at := c.lastConns, c.lastAt
+	c.lastMu.RUnlock()
+
+	if !at.IsZero() && time.Since(at) <= 2*c.interval {
+		return conns, nil
+	}
+	return c.connections.GetConnections(ctx)
+}
+
+// History возвращает сессии этого роутера из истории.
+func (c *CollectService) History(ctx context.Context, f storage.HistoryFilter) ([]domain.Session, error) {
+	f.Router = c.connections.router
+	return c.repo.FindHistory(ctx, f)
+}
+
+// Series — ряд числа активных соединений для домена или адреса назначения (dstIP != "").
+func (c *CollectService) Series(ctx context.Context, bucket storage.Bucket, domain, dstIP string, from, to time.Time) ([]storage.SeriesPoint, error) {
+	if dstIP != "" {
+		return c.repo.FindDstSeries(ctx, c.connections.router, bucket, dstIP, domain, from, to)
+	}
+	return c.repo.FindDomainSeries(ctx, c.connections.router, bucket, domain, from, to)
+}
+
+// TopTraffic — самые активные по байтам домены, адреса назначения или LAN-клиенты (by) с from.
+func (c *CollectService) TopTraffic(ctx context.Context, by string, bucket storage.Bucket, from time.Time, limit int) ([]storage.TrafficRank, error) {
+	return c.repo.TopTraffic(ctx, c.connections.router, by, bucket, from, limit)
+}
+
+// DNSMappings — запомненные имена адреса ip на этом роутере.
+func (c *CollectService) DNSMappings(ctx context.Context, ip string) ([]storage.DNSMapping, error) {
+	return c.repo.FindDNSMappingsForIP(ctx, c.connections.router, canonIP(ip))
+}
+
+// GroupByDomain — режим GetByDNS: строки объединяются по регистрируемому домену (eTLD+1).
+const GroupByDomain = "domain"
+
+// GetByDNS возвращает домены с числом активных соединений; group == GroupByDomain —
+// сгруппированные по регистрируемому домену (см. groupByRegistrable).
+func (c *CollectService) GetByDNS(ctx context.Context, name, group string) ([]map[string]any, error) {
+	rows, err := c.getByDNS(ctx, name)
+	if err != nil || group != GroupByDomain {
+		return rows, err
+	}
+	return groupByRegistrable(rows), nil
+}
+
+func (c *CollectService) getByDNS(ctx context.Context, name string) ([]map[string]any, error) {
+	rows, err := c.repo.FindDstCountsLike(ctx, c.connections.router, name) // читаем dst_conn_counts
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +205,7 @@ func (c *CollectService) GetByDNS(ctx context.Context, name string) ([]map[strin
 		updated  string
 		ipsSeen  map[string]struct{}
 		ipsSlice []map[string]any
+		aliases  []string
 	}
 
 	byDNS := map[string]*agg{}
@@ -111,31 +232,53 @@ func (c *CollectService) GetByDNS(ctx context.Context, name string) ([]map[strin
 
 		if _, ok := a.ipsSeen[ip]; !ok {
 			a.ipsSeen[ip] = struct{}{}
-			a.ipsSlice = append(a.ipsSlice, map[string]any{
+			item := map[string]any{
 				"dstIP":  ip,
 				"dstDNS": dns,
-			})
+			}
+			if len(r.CNAMEs) > 0 {
+				item["cnames"] = r.CNAMEs
+			}
+			if len(r.OtherNames) > 0 {
+				item["otherNames"] = r.OtherNames
+			}
+			a.ipsSlice = append(a.ipsSlice, item)
+		}
+		for _, n := range r.CNAMEs {
+			if !slices.Contains(a.aliases, n) {
+				a.aliases = append(a.aliases, n)
+			}
 		}
 	}
 
 	// в массив
 	out := make([]map[string]any, 0, len(byDNS))
 	for _, a := range byDNS {
-		ignore, _ := c.connections.IsIgnoreVPN(ctx, a.dns)
+		entries, _ := c.connections.IgnoreVPNEntries(ctx, a.dns)
 
 		out = append(out, map[string]any{
-			"dstDns":            a.dns,
-			"activeConnections": a.sum,
-			"dnsConnections":    a.ipsSlice,
-			"isIgnoreVpn":       ignore,
-			"updatedAt":         a.updated,
+			"router":             c.connections.router,
+			"dstDns":             a.dns,
+			"activeConnections":  a.sum,
+			"dnsConnections":     a.ipsSlice,
+			"aliases":            append([]string{}, a.aliases...), // все CNAME, через которые домен вёл к адресам
+			"isIgnoreVpn":        entries != nil,
+			"ignoreVpnEntry":     strings.Join(entries, ","), // запись листа, давшая совпадение
+			"ignoreVpnUpdatedAt": c.connections.IgnoreVPNUpdatedAt(),
+			"updatedAt":          a.updated,
 		})
 	}
 
 	// сортировка как раньше: по activeConnections desc
-	sort.Slice(out, func(i, j int) bool {
-		return out[i]["activeConnections"].(int64) > out[j]["activeConnections"].(int64)
-	})
+	SortByActiveConnections(out)
 
 	return out, nil
 }
+
+// SortByActiveConnections сортирует строки GetByDNS по activeConnections desc
+// (нужно и после склейки ответов нескольких роутеров).
+func SortByActiveConnections(rows []map[string]any) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i]["activeConnections"].(int64) > rows[j]["activeConnections"].(int64)
+	})
+}
@@ -2,74 +2,141 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
 
+	"mikrotik-parser-go/internal/catalog"
 	"mikrotik-parser-go/internal/domain"
 	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
 )
 
 type ConnectionsService struct {
-	mt *mikrotik.Client
+	router string
+	mt     mikrotik.API
+	ipv6   bool
 
 	ignoreVPNListName      string
 	ignoreLanToVpnListName string
+	ignoreVPNMatch         MatchMode
+	// другие листы, которыми можно управлять через API (allow-list из конфигурации)
+	extraLists []string
+
+	// address-листы в памяти: обновляет коллектор, свои записи сбрасывают
+	lists addressListCache
+
+	catalog *catalog.Catalog
+
+	// память адрес -> домен на случай, когда запись ушла из DNS-кэша роутера
+	dnsMemory *storage.Sqlite
+	dnsMaxAge time.Duration
+
+	// журнал изменений address-листов
+	audit *storage.Sqlite
+	// снимки address-листов для отката
+	snapshots *storage.Sqlite
 }
 
-func NewConnectionsService(mt *mikrotik.Client, ignoreVPNListName, ignoreLanToVpnListName string) *ConnectionsService {
+func NewConnectionsService(router string, mt mikrotik.API, ignoreVPNListName, ignoreLanToVpnListName string, ipv6 bool) *ConnectionsService {
 	return &ConnectionsService{
+		router:                 router,
 		mt:                     mt,
+		ipv6:                   ipv6,
 		ignoreVPNListName:      ignoreVPNListName,
 		ignoreLanToVpnListName: ignoreLanToVpnListName,
+		ignoreVPNMatch:         MatchExact,
+	}
+}
+
+// UseDNSMemory включает запоминание связей адрес -> домен из DNS-кэша; запомненная связь
+// подставляется, если адреса в кэше нет, но запись истекла не раньше maxAge назад
+// (maxAge == 0 — только запоминать).
+func (s *ConnectionsService) UseDNSMemory(repo *storage.Sqlite, maxAge time.Duration) {
+	s.dnsMemory = repo
+	s.dnsMaxAge = maxAge
+}
+
+// Router возвращает имя роутера, которое обслуживает сервис.
+func (s *ConnectionsService) Router() string { return s.router }
+
+// families — семейства адресов, с которыми работает роутер.
+func (s *ConnectionsService) families() []mikrotik.Family {
+	if s.ipv6 {
+		return []mikrotik.Family{mikrotik.IPv4, mikrotik.IPv6}
 	}
+	return []mikrotik.Family{mikrotik.IPv4}
 }
 
-func stripPort(s string) string {
-	if strings.Count(s, ":") == 1 {
-		i := strings.LastIndex(s, ":")
-		if i > 0 {
-			if _, err := strconv.Atoi(s[i+1:]); err == nil {
-				return s[:i]
+// portProtocols — протоколы, у которых в src/dst-address есть порт.
+var portProtocols = map[string]bool{"tcp": true, "udp": true, "udp-lite": true, "sctp": true, "dccp": true}
+
+// splitPort делит адрес соединения на хост и порт для обоих семейств: "1.2.3.4:443",
+// "[2a00::1]:443" и "2a00::1:443" (IPv6 с портом через двоеточие). Последний вариант
+// неоднозначен, поэтому withPort говорит, есть ли у протокола порт вообще; без портов
+// (icmp, gre) адрес возвращается целиком. Хост приводится к каноническому виду.
+func splitPort(s string, withPort bool) (string, string) {
+	s = strings.TrimSpace(s)
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap().String(), strconv.Itoa(int(ap.Port()))
+	}
+	if a, err := netip.ParseAddr(s); err == nil && !withPort {
+		return a.Unmap().String(), ""
+	}
+	if i := strings.LastIndex(s, ":"); i > 0 {
+		if _, err := strconv.ParseUint(s[i+1:], 10, 16); err == nil {
+			if a, err := netip.ParseAddr(s[:i]); err == nil {
+				return a.Unmap().String(), s[i+1:]
 			}
 		}
 	}
+	return canonIP(s), ""
+}
+
+// canonIP — канонический вид адреса (сжатый IPv6 в нижнем регистре), чтобы адреса
+// из conntrack, DNS-кэша и address-list совпадали как строки. Не адрес — как есть.
+func canonIP(s string) string {
+	s = strings.TrimSpace(s)
+	if a, err := netip.ParseAddr(s); err == nil {
+		return a.Unmap().String()
+	}
 	return s
 }
 
-func (s *ConnectionsService) GetConnections(ctx context.Context) ([]domain.Connection, error) {
-	ctx, cancel := context.WithTimeout(ctx, 6*time.Second)
-	defer cancel()
+// lanNames — имена LAN-клиентов: аренды DHCP по адресу и привязки DHCPv6 по префиксу.
+type lanNames struct {
+	byIP     map[string]string
+	prefixes []namedPrefix
+}
 
-	dnsRows, err := s.mt.DNSCache(ctx)
-	if err != nil {
-		return nil, err
-	}
-	leaseRows, err := s.mt.DHCPLeases(ctx)
-	if err != nil {
-		return nil, err
-	}
-	connRows, err := s.mt.FirewallConnections(ctx)
-	if err != nil {
-		return nil, err
-	}
+type namedPrefix struct {
+	prefix netip.Prefix
+	name   string
+}
 
-	dnsByIP := map[string]string{}
-	for _, r := range dnsRows {
-		ip := r["data"]
-		if ip == "" {
-			ip = r["address"]
-		}
-		name := r["name"]
-		if name == "" {
-			name = r["dns-name"]
-		}
-		if ip != "" && name != "" {
-			dnsByIP[ip] = name
+func (n lanNames) lookup(ip string) string {
+	if name := n.byIP[ip]; name != "" {
+		return name
+	}
+	if a, err := netip.ParseAddr(ip); err == nil {
+		for _, p := range n.prefixes {
+			if p.prefix.Contains(a) {
+				return p.name
+			}
 		}
 	}
+	return ""
+}
+
+func (s *ConnectionsService) loadLanNames(ctx context.Context) (lanNames, error) {
+	names := lanNames{byIP: map[string]string{}}
 
-	hostByIP := map[string]string{}
+	leaseRows, err := s.mt.DHCPLeases(ctx)
+	if err != nil {
+		return names, err
+	}
 	for _, r := range leaseRows {
 		ip := r["active-address"]
 		if ip == "" {
@@ -83,29 +150,159 @@ func (s *ConnectionsService) GetConnections(ctx context.Context) ([]domain.Conne
 			host = r["comment"]
 		}
 		if ip != "" && host != "" {
-			hostByIP[ip] = host
+			names.byIP[canonIP(ip)] = host
 		}
 	}
 
-	out := make([]domain.Connection, 0, len(connRows))
+	if !s.ipv6 {
+		return names, nil
+	}
+	bindingRows, err := s.mt.DHCPv6Bindings(ctx)
+	if err != nil {
+		return names, err
+	}
+	// у привязок DHCPv6 нет host-name — только comment
+	for _, r := range bindingRows {
+		host := r["comment"]
+		if host == "" {
+			continue
+		}
+		addr := r["address"]
+		if p, err := netip.ParsePrefix(addr); err == nil {
+			names.prefixes = append(names.prefixes, namedPrefix{prefix: p.Masked(), name: host})
+		} else if a, err := netip.ParseAddr(addr); err == nil {
+			names.byIP[a.String()] = host
+		}
+	}
+	return names, nil
+}
+
+// parseCounter: счётчик conntrack; пустое или нечисловое значение — 0.
+func parseCounter(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// connProps, dnsProps — поля conntrack и DNS-кэша, которые читает GetConnections (.proplist).
+var (
+	connProps = []string{
+		".id", "protocol", "src-address", "dst-address",
+		"orig-bytes", "repl-bytes", "orig-packets", "repl-packets",
+		"tcp-state", "timeout", "connection-mark",
+	}
+	dnsProps = []string{"name", "type", "data", "ttl", "address"}
+)
+
+func (s *ConnectionsService) GetConnections(ctx context.Context) ([]domain.Connection, error) {
+	return s.getConnections(ctx, nil)
+}
+
+// connID — ключ соединения: .id уникален только внутри таблицы conntrack,
+// и у IPv4 и IPv6 нумерация своя, поэтому к нему добавляется семейство.
+func connID(fam mikrotik.Family, id string) string {
+	if id == "" {
+		return ""
+	}
+	return string(fam) + id
+}
+
+// getConnections читает соединения; filter — слова запроса conntrack по семействам
+// (nil — все соединения; filter вернул nil — семейство не читается, пустой срез — вся таблица).
+func (s *ConnectionsService) getConnections(ctx context.Context, filter func(fam mikrotik.Family) []string) ([]domain.Connection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 6*time.Second)
+	defer cancel()
+
+	dnsRows, err := s.mt.DNSCache(ctx, mikrotik.Query{Props: dnsProps})
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.loadLanNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		connRows []map[string]string
+		connFams []mikrotik.Family // семейство каждой строки connRows
+	)
+	for _, fam := range s.families() {
+		q := mikrotik.Query{Props: connProps}
+		if filter != nil {
+			if q.Filter = filter(fam); q.Filter == nil {
+				continue
+			}
+		}
+		rows, err := s.mt.FirewallConnections(ctx, fam, q)
+		if err != nil {
+			return nil, err
+		}
+		connRows = append(connRows, rows...)
+		for range rows {
+			connFams = append(connFams, fam)
+		}
+	}
+
+	dnsByIP := resolveDNSCache(dnsRows)
+
+	var missing []string
+	seen := map[string]bool{}
 	for _, r := range connRows {
-		src := stripPort(r["src-address"])
-		dst := stripPort(r["dst-address"])
+		dst, _ := splitPort(r["dst-address"], portProtocols[r["protocol"]])
+		if _, ok := dnsByIP[dst]; !ok && dst != "" && !seen[dst] {
+			seen[dst] = true
+			missing = append(missing, dst)
+		}
+	}
+	s.rememberDNS(ctx, dnsByIP, missing)
+
+	out := make([]domain.Connection, 0, len(connRows))
+	for i, r := range connRows {
+		withPort := portProtocols[r["protocol"]]
+		src, srcPort := splitPort(r["src-address"], withPort)
+		dst, dstPort := splitPort(r["dst-address"], withPort)
 		if src == "" || dst == "" {
 			continue
 		}
+		dns := dnsByIP[dst]
 		out = append(out, domain.Connection{
+			ID:       connID(connFams[i], r[".id"]),
+			Router:   s.router,
+			Protocol: r["protocol"],
 			SrcIP:    src,
+			SrcPort:  srcPort,
 			DstIP:    dst,
-			DstDNS:   dnsByIP[dst],
-			HostName: hostByIP[src],
+			DstPort:  dstPort,
+			DstDNS:   dns.Name,
+			HostName: names.lookup(src),
+
+			DstCNAMEs:     dns.CNAMEs,
+			DstOtherNames: dns.Others,
+			DstDNSSource:  dns.Source,
+
+			OrigBytes:      parseCounter(r["orig-bytes"]),
+			ReplBytes:      parseCounter(r["repl-bytes"]),
+			OrigPackets:    parseCounter(r["orig-packets"]),
+			ReplPackets:    parseCounter(r["repl-packets"]),
+			TCPState:       r["tcp-state"],
+			Timeout:        r["timeout"],
+			ConnectionMark: r["connection-mark"],
 		})
 	}
 	return out, nil
 }
 
 func (s *ConnectionsService) GetBySrc(ctx context.Context, srcIP string) ([]domain.GroupedDnsConnection, error) {
-	conns, err := s.GetConnections(ctx)
+	srcIP = canonIP(srcIP)
+
+	// таблицу другого семейства не читаем вовсе. Источник внутри семейства на роутере
+	// не сужаем: у tcp/udp src-address — "адрес:порт", а сравнения ?< / ?> в запросах
+	// API не обязаны быть строковыми, так что диапазоном "адрес:".."адрес;" его не выбрать;
+	// точное ?src-address= нашло бы только протоколы без порта. Сверяем после разбора адреса.
+	conns, err := s.getConnections(ctx, func(fam mikrotik.Family) []string {
+		if mikrotik.FamilyOf(srcIP) != fam {
+			return nil
+		}
+		return []string{}
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -116,81 +313,112 @@ func (s *ConnectionsService) GetBySrc(ctx context.Context, srcIP string) ([]doma
 			continue
 		}
 		key := c.DstDNS
-		group[key] = append(group[key], domain.DnsConnection{DstIP: c.DstIP, DstDNS: c.DstDNS})
+		group[key] = append(group[key], domain.DnsConnection{DstIP: c.DstIP, DstDNS: c.DstDNS, CNAMEs: c.DstCNAMEs})
 	}
 
 	res := make([]domain.GroupedDnsConnection, 0, len(group))
 	for dns, items := range group {
-		ignore, _ := s.IsIgnoreVPN(ctx, dns)
+		entries, _ := s.IgnoreVPNEntries(ctx, dns)
 		res = append(res, domain.GroupedDnsConnection{
-			DstDNS:      dns,
-			Items:       items,
-			IsIgnoreVPN: ignore,
+			Router:             s.router,
+			DstDNS:             dns,
+			Items:              items,
+			IsIgnoreVPN:        entries != nil,
+			IgnoreVPNEntry:     strings.Join(entries, ","),
+			IgnoreVPNUpdatedAt: s.IgnoreVPNUpdatedAt(),
 		})
 	}
 	return res, nil
 }
 
-func (s *ConnectionsService) PostDnsToIgnoreList(ctx context.Context, domains string, enabled bool) error {
-	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
-	defer cancel()
+// PostDnsToIgnoreList включает/выключает домены в ignore-VPN листе; при IPv6 — в листах
+// обоих семейств (роутер резолвит домен в A для /ip и в AAAA для /ipv6).
+// Элемент "service:Имя" раскрывается в домены и подсети сервиса из каталога.
+// opts (комментарий, timeout) применяются к добавленным и включённым записям.
+func (s *ConnectionsService) PostDnsToIgnoreList(ctx context.Context, domains string, enabled bool, opts mikrotik.EntryOptions) error {
+	return s.changeIgnoreVPN(ctx, domains, opFor(enabled), opts)
+}
+
+// RemoveDnsFromIgnoreList удаляет записи доменов (и сервисов каталога) из ignore-VPN листа.
+func (s *ConnectionsService) RemoveDnsFromIgnoreList(ctx context.Context, domains string) error {
+	return s.changeIgnoreVPN(ctx, domains, OpRemove, mikrotik.EntryOptions{})
+}
 
-	addresses, err := s.mt.AddressListIgnoreVPN(ctx, s.ignoreVPNListName)
+func (s *ConnectionsService) changeIgnoreVPN(ctx context.Context, domains, op string, opts mikrotik.EntryOptions) error {
+	entries, err := s.expandServices(mikrotik.SplitDomainsCSV(domains))
 	if err != nil {
 		return err
 	}
+	_, err = s.ChangeList(ctx, s.ignoreVPNListName, ChangesFor(entries, op, opts))
+	return err
+}
 
-	byAddress := map[string]map[string]string{}
-	for _, r := range addresses {
-		if a := r["address"]; a != "" {
-			byAddress[a] = r
-		}
+// autoEntry — динамическая запись, которую создал сам роутер (резолв домена в адреса);
+// временные записи с timeout тоже динамические, но их добавили через API.
+func autoEntry(r map[string]string) bool {
+	return r["dynamic"] == "true" && r["timeout"] == ""
+}
+
+func opFor(enabled bool) string {
+	if enabled {
+		return OpEnable
 	}
+	return OpDisable
+}
 
-	for _, domain := range mikrotik.SplitDomainsCSV(domains) {
-		row := byAddress[domain]
-		id := ""
-		if row != nil {
-			id = row[".id"]
-		}
+// applyEntry выполняет op над записью листа: row == nil — записи нет
+// (enable её добавляет, disable и remove ничего не делают).
+func (s *ConnectionsService) applyEntry(ctx context.Context, fam mikrotik.Family, list, address string, row map[string]string, op string, opts mikrotik.EntryOptions) error {
+	switch {
+	case op == OpEnable && row == nil:
+		return s.mt.AddressListAdd(ctx, fam, list, address, opts)
+	case row == nil:
+		return nil
+	case op == OpEnable:
+		return s.mt.AddressListSetDisabled(ctx, fam, row[".id"], false, opts)
+	case op == OpDisable:
+		return s.mt.AddressListSetDisabled(ctx, fam, row[".id"], true, mikrotik.EntryOptions{Comment: opts.Comment})
+	default:
+		return s.mt.AddressListRemove(ctx, fam, row[".id"])
+	}
+}
 
-		if enabled {
-			if id != "" {
-				if err := s.mt.AddressListSetDisabled(ctx, id, false); err != nil {
-					return err
-				}
-			} else {
-				if err := s.mt.AddressListAdd(ctx, s.ignoreVPNListName, domain); err != nil {
-					return err
-				}
-			}
-		} else {
-			if id != "" {
-				if err := s.mt.AddressListSetDisabled(ctx, id, true); err != nil {
-					return err
-				}
-			}
-		}
+// targetState — состояние записи после op: выключение отсутствующей записи её не создаёт.
+func targetState(row map[string]string, op string) string {
+	switch {
+	case op == OpEnable:
+		return storage.StateEnabled
+	case op == OpRemove, row == nil:
+		return storage.StateAbsent
+	default:
+		return storage.StateDisabled
 	}
-	return nil
 }
 
 func (s *ConnectionsService) IsIgnoreVPN(ctx context.Context, dns string) (bool, error) {
+	entries, err := s.IgnoreVPNEntries(ctx, dns)
+	return entries != nil, err
+}
+
+// IgnoreVPNEntries возвращает для каждого домена из dns (CSV) запись листа, которая его
+// покрывает (с учётом режима сопоставления), или nil, если хотя бы один домен не покрыт.
+func (s *ConnectionsService) IgnoreVPNEntries(ctx context.Context, dns string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 6*time.Second)
 	defer cancel()
 
 	if strings.TrimSpace(dns) == "" {
-		return false, nil
+		return nil, nil
 	}
 
-	addresses, err := s.mt.AddressListIgnoreVPN(ctx, s.ignoreVPNListName)
+	// источник истины — лист IPv4, лист IPv6 ведётся вместе с ним
+	addresses, _, err := s.addressList(ctx, mikrotik.IPv4, s.ignoreVPNListName)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	enabledStatic := map[string]bool{}
 	for _, r := range addresses {
-		if r["dynamic"] == "true" {
+		if autoEntry(r) {
 			continue
 		}
 		if r["disabled"] == "true" {
@@ -201,55 +429,53 @@ func (s *ConnectionsService) IsIgnoreVPN(ctx context.Context, dns string) (bool,
 		}
 	}
 
+	var out []string
 	for _, d := range mikrotik.SplitDomainsCSV(dns) {
-		if !enabledStatic[d] {
-			return false, nil
+		entry := matchIgnoreEntry(s.ignoreVPNMatch, enabledStatic, d)
+		if entry == "" {
+			return nil, nil
 		}
+		out = append(out, entry)
 	}
-	return true, nil
+	return out, nil
 }
 
 type IgnoreLanToVpnItem struct {
+	Router   string `json:"router,omitempty"`
 	IP       string `json:"ip"`
 	HostName string `json:"hostName"`
 	Enabled  bool   `json:"enabled"`
 	Dynamic  bool   `json:"dynamic"`
+	Comment  string `json:"comment,omitempty"`
+	Timeout  string `json:"timeout,omitempty"` // сколько осталось до удаления временной записи
+
+	ListUpdatedAt time.Time `json:"listUpdatedAt"` // когда лист был прочитан с роутера
 }
 
 func (s *ConnectionsService) GetIgnoreLanToVpn(ctx context.Context, find string) ([]IgnoreLanToVpnItem, error) {
 	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
 
-	// адрес-лист ignoreLanToVpn
-	addresses, err := s.mt.AddressListIgnoreVPN(ctx, s.ignoreLanToVpnListName) // метод уже есть, просто другой listName
-	if err != nil {
-		return nil, err
+	// адрес-лист ignoreLanToVpn (при IPv6 — оба семейства)
+	var addresses []map[string]string
+	var updatedAt time.Time
+	for _, fam := range s.families() {
+		rows, at, err := s.addressList(ctx, fam, s.ignoreLanToVpnListName)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, rows...)
+		if updatedAt.IsZero() || at.Before(updatedAt) {
+			updatedAt = at
+		}
 	}
 
 	// DHCP leases для матчинга IP -> active host name
-	leaseRows, err := s.mt.DHCPLeases(ctx)
+	names, err := s.loadLanNames(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	hostByIP := map[string]string{}
-	for _, r := range leaseRows {
-		ip := r["active-address"]
-		if ip == "" {
-			ip = r["address"]
-		}
-		host := r["active-host-name"]
-		if host == "" {
-			host = r["host-name"]
-		}
-		if host == "" {
-			host = r["comment"]
-		}
-		if ip != "" && host != "" {
-			hostByIP[ip] = host
-		}
-	}
-
 	find = strings.ToLower(strings.TrimSpace(find))
 
 	out := make([]IgnoreLanToVpnItem, 0, len(addresses))
@@ -259,7 +485,7 @@ func (s *ConnectionsService) GetIgnoreLanToVpn(ctx context.Context, find string)
 			continue
 		}
 
-		host := hostByIP[ip]
+		host := names.lookup(canonIP(ip))
 		if host == "" {
 			// fallback: если в address-list записан comment
 			host = strings.TrimSpace(r["comment"])
@@ -278,46 +504,39 @@ func (s *ConnectionsService) GetIgnoreLanToVpn(ctx context.Context, find string)
 		}
 
 		out = append(out, IgnoreLanToVpnItem{
+			Router:   s.router,
 			IP:       ip,
 			HostName: host,
 			Enabled:  enabled,
 			Dynamic:  dyn,
+			Comment:  strings.TrimSpace(r["comment"]),
+			Timeout:  r["timeout"],
+
+			ListUpdatedAt: updatedAt,
 		})
 	}
 	return out, nil
 }
 
-func (s *ConnectionsService) PostIpToIgnoreLanToVpn(ctx context.Context, ip string, enabled bool) error {
-	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
-	defer cancel()
+// PostIpToIgnoreLanToVpn включает/выключает адрес клиента в листе ignoreLanToVpn;
+// opts (комментарий, timeout) применяются к добавленной или включённой записи.
+func (s *ConnectionsService) PostIpToIgnoreLanToVpn(ctx context.Context, ip string, enabled bool, opts mikrotik.EntryOptions) error {
+	return s.changeIgnoreLanToVpn(ctx, ip, opFor(enabled), opts)
+}
+
+// RemoveIpFromIgnoreLanToVpn удаляет адрес клиента из листа ignoreLanToVpn.
+func (s *ConnectionsService) RemoveIpFromIgnoreLanToVpn(ctx context.Context, ip string) error {
+	return s.changeIgnoreLanToVpn(ctx, ip, OpRemove, mikrotik.EntryOptions{})
+}
 
+func (s *ConnectionsService) changeIgnoreLanToVpn(ctx context.Context, ip, op string, opts mikrotik.EntryOptions) error {
 	ip = strings.TrimSpace(ip)
 	if ip == "" {
 		return nil
 	}
-
-	addresses, err := s.mt.AddressListIgnoreVPN(ctx, s.ignoreLanToVpnListName)
-	if err != nil {
-		return err
-	}
-
-	var id string
-	for _, r := range addresses {
-		if strings.TrimSpace(r["address"]) == ip {
-			id = r[".id"]
-			break
-		}
-	}
-
-	if enabled {
-		if id != "" {
-			return s.mt.AddressListSetDisabled(ctx, id, false)
-		}
-		return s.mt.AddressListAdd(ctx, s.ignoreLanToVpnListName, ip)
-	}
-
-	if id != "" {
-		return s.mt.AddressListSetDisabled(ctx, id, true)
+	if !isAddress(ip) {
+		return fmt.Errorf("%w: %q is not an IP address", ErrBadChange, ip)
 	}
-	return nil
+	_, err := s.ChangeList(ctx, s.ignoreLanToVpnListName, ChangesFor([]string{ip}, op, opts))
+	return err
 }
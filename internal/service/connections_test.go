@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/mikrotik/fake"
+)
+
+func startFake(t *testing.T) *fake.Server {
+	t.Helper()
+	srv := fake.New()
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	for _, path := range []string{
+		"/ip/dns/cache/all", "/ip/dhcp-server/lease", "/ipv6/dhcp-server/binding",
+		"/ip/firewall/connection", "/ipv6/firewall/connection",
+		"/ip/firewall/address-list", "/ipv6/firewall/address-list",
+	} {
+		srv.SetTable(path, nil)
+	}
+	return srv
+}
+
+// У IPv4 и IPv6 своя нумерация .id: одинаковые .id разных семейств — разные соединения.
+func TestConnectionIDsOverlapAcrossFamilies(t *testing.T) {
+	srv := startFake(t)
+	srv.SetTable("/ip/firewall/connection", []map[string]string{
+		{"protocol": "tcp", "src-address": "192.168.88.10:50000", "dst-address": "1.1.1.1:443", "orig-bytes": "100"},
+	})
+	srv.SetTable("/ipv6/firewall/connection", []map[string]string{
+		{"protocol": "tcp", "src-address": "[fd00::10]:50000", "dst-address": "[2606:4700::1111]:443", "orig-bytes": "200"},
+	})
+	v4, v6 := srv.Table("/ip/firewall/connection"), srv.Table("/ipv6/firewall/connection")
+	if v4[0][".id"] != v6[0][".id"] {
+		t.Fatalf("fake ids differ: %s vs %s", v4[0][".id"], v6[0][".id"])
+	}
+
+	s := NewConnectionsService("r1", mikrotik.New(srv.Addr(), "admin", ""), "", "", true)
+	conns, err := s.GetConnections(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 2 {
+		t.Fatalf("got %d connections, want 2", len(conns))
+	}
+	if conns[0].ID == conns[1].ID {
+		t.Fatalf("connections share ID %q", conns[0].ID)
+	}
+
+	var tr trafficTracker
+	tr.deltas(conns)
+	conns[0].OrigBytes += 10
+	conns[1].OrigBytes += 20
+	d := tr.deltas(conns)
+	if d[0].OrigBytes != 10 || d[1].OrigBytes != 20 {
+		t.Fatalf("deltas = %d, %d; want 10, 20", d[0].OrigBytes, d[1].OrigBytes)
+	}
+
+	var st streamTracker
+	snap, _ := st.update("r1", time.Now(), conns, nil)
+	if len(snap.Opened) != 2 {
+		t.Fatalf("snapshot has %d connections, want 2", len(snap.Opened))
+	}
+	_, delta := st.update("r1", time.Now(), conns[:1], nil)
+	if len(delta.Closed) != 1 || delta.Closed[0].ID != conns[1].ID {
+		t.Fatalf("closed = %+v, want %s", delta.Closed, conns[1].ID)
+	}
+}
+
+// GetBySrc находит соединения с портом и без, но не соседние адреса с тем же префиксом.
+func TestGetBySrcMatchesExactSource(t *testing.T) {
+	srv := startFake(t)
+	srv.SetTable("/ip/firewall/connection", []map[string]string{
+		{"protocol": "tcp", "src-address": "192.168.88.1:50000", "dst-address": "1.1.1.1:443"},
+		{"protocol": "icmp", "src-address": "192.168.88.1", "dst-address": "8.8.8.8"},
+		{"protocol": "tcp", "src-address": "192.168.88.10:50001", "dst-address": "9.9.9.9:443"},
+	})
+
+	s := NewConnectionsService("r1", mikrotik.New(srv.Addr(), "admin", ""), "", "", false)
+	groups, err := s.GetBySrc(context.Background(), "192.168.88.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, g := range groups {
+		for _, it := range g.Items {
+			got[it.DstIP] = true
+		}
+	}
+	if len(got) != 2 || !got["1.1.1.1"] || !got["8.8.8.8"] {
+		t.Fatalf("destinations = %v, want 1.1.1.1 and 8.8.8.8", got)
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// dnsName — что известно об адресе назначения из DNS-кэша.
+type dnsName struct {
+	Name   string   // имя, которое запрашивал клиент
+	CNAMEs []string // цепочка от Name до имени A/AAAA-записи (без самого Name)
+	Others []string // другие запрошенные имена с тем же адресом
+	TTL    time.Duration
+	Source string // "cache" — DNS-кэш роутера, "memory" — запомненная связь (dns_mappings)
+}
+
+type cnameEdge struct {
+	from string
+	ttl  time.Duration
+}
+
+// resolveDNSCache строит адрес -> имена по записям /ip/dns/cache/all: от имени
+// A/AAAA-записи идём по CNAME назад до имён, у которых своих CNAME-предков нет,
+// — их и запрашивали клиенты. Если таких имён несколько (общий адрес CDN),
+// основным считается то, что резолвилось последним (больший остаток ttl).
+func resolveDNSCache(rows []map[string]string) map[string]dnsName {
+	type addrRec struct {
+		name string
+		ttl  time.Duration
+	}
+	addrs := map[string][]addrRec{}
+	parents := map[string][]cnameEdge{} // цель CNAME -> кто на неё ссылается
+
+	for _, r := range rows {
+		name := normDNSName(r["name"])
+		if name == "" {
+			name = normDNSName(r["dns-name"])
+		}
+		data := strings.TrimSpace(r["data"])
+		if data == "" {
+			data = strings.TrimSpace(r["address"])
+		}
+		if name == "" || data == "" {
+			continue
+		}
+		ttl, _ := mikrotik.ParseDuration(r["ttl"])
+
+		switch r["type"] {
+		case "CNAME":
+			target := normDNSName(data)
+			parents[target] = append(parents[target], cnameEdge{from: name, ttl: ttl})
+		case "A", "AAAA", "":
+			// старый /ip/dns/cache/print без type — только адресные записи
+			a, err := netip.ParseAddr(data)
+			if err != nil {
+				continue
+			}
+			ip := a.Unmap().String()
+			addrs[ip] = append(addrs[ip], addrRec{name: name, ttl: ttl})
+		}
+	}
+
+	type root struct {
+		name   string
+		cnames []string
+		ttl    time.Duration
+	}
+	// roots ищет запрошенные имена для name; path — цепочка от name до A-записи
+	var roots func(name string, path []string, ttl time.Duration, seen map[string]bool) []root
+	roots = func(name string, path []string, ttl time.Duration, seen map[string]bool) []root {
+		if seen[name] {
+			return nil // цикл CNAME
+		}
+		seen[name] = true
+		defer delete(seen, name)
+
+		ps := parents[name]
+		if len(ps) == 0 {
+			return []root{{name: name, cnames: slices.Clone(path), ttl: ttl}}
+		}
+		var out []root
+		for _, p := range ps {
+			out = append(out, roots(p.from, append([]string{name}, path...), p.ttl, seen)...)
+		}
+		return out
+	}
+
+	out := make(map[string]dnsName, len(addrs))
+	for ip, recs := range addrs {
+		var all []root
+		for _, rec := range recs {
+			all = append(all, roots(rec.name, nil, rec.ttl, map[string]bool{})...)
+		}
+		if len(all) == 0 {
+			continue
+		}
+		slices.SortFunc(all, func(a, b root) int {
+			return cmp.Or(cmp.Compare(b.ttl, a.ttl), strings.Compare(a.name, b.name))
+		})
+
+		res := dnsName{Name: all[0].name, CNAMEs: all[0].cnames, TTL: all[0].ttl, Source: "cache"}
+		for _, r := range all[1:] {
+			if r.name != res.Name && !slices.Contains(res.Others, r.name) {
+				res.Others = append(res.Others, r.name)
+			}
+		}
+		out[ip] = res
+	}
+	return out
+}
+
+// normDNSName: нижний регистр, без завершающей точки.
+func normDNSName(s string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+}
+
+// rememberDNS сохраняет связи текущего опроса кэша и дополняет byIP запомненными
+// связями для адресов missing, которых в кэше уже нет.
+func (s *ConnectionsService) rememberDNS(ctx context.Context, byIP map[string]dnsName, missing []string) {
+	if s.dnsMemory == nil {
+		return
+	}
+
+	var items []storage.DNSMapping
+	for ip, n := range byIP {
+		ttl := int64(n.TTL / time.Second)
+		items = append(items, storage.DNSMapping{IP: ip, Name: n.Name, CNAMEs: n.CNAMEs, TTL: ttl})
+		// у других имён ttl 0: при равном last_seen в FindDNSMappings побеждает основное
+		for _, other := range n.Others {
+			items = append(items, storage.DNSMapping{IP: ip, Name: other})
+		}
+	}
+	if err := s.dnsMemory.SaveDNSMappings(ctx, s.router, time.Now(), items); err != nil {
+		log.Printf("%s: save dns mappings: %v", s.router, err)
+	}
+
+	if len(missing) == 0 || s.dnsMaxAge <= 0 {
+		return
+	}
+	found, err := s.dnsMemory.FindDNSMappings(ctx, s.router, missing, time.Now().Add(-s.dnsMaxAge))
+	if err != nil {
+		log.Printf("%s: find dns mappings: %v", s.router, err)
+		return
+	}
+	for ip, m := range found {
+		byIP[ip] = dnsName{Name: m.Name, CNAMEs: m.CNAMEs, Source: "memory"}
+	}
+}
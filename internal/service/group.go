@@ -0,0 +1,64 @@
+package service
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// registrableDomain — eTLD+1 по встроенному Public Suffix List: rr1---sn-x.googlevideo.com ->
+// googlevideo.com, www.bbc.co.uk -> bbc.co.uk. Если не получилось (адрес, сам суффикс) — host как есть.
+func registrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+// groupByRegistrable объединяет строки getByDNS одного роутера по регистрируемому домену:
+// сумма соединений, домены группы в members и состояние ignore-VPN группы —
+// isIgnoreVpn (все домены в листе) и ignoreVpnMembers (сколько из них).
+func groupByRegistrable(rows []map[string]any) []map[string]any {
+	byGroup := map[string]map[string]any{}
+	var order []string
+
+	for _, r := range rows {
+		key := registrableDomain(r["dstDns"].(string))
+		g := byGroup[key]
+		if g == nil {
+			g = map[string]any{
+				"router":             r["router"],
+				"group":              key,
+				"activeConnections":  int64(0),
+				"isIgnoreVpn":        true,
+				"ignoreVpnMembers":   0,
+				"ignoreVpnUpdatedAt": r["ignoreVpnUpdatedAt"], // лист у всех строк роутера один
+				"updatedAt":          "",
+				"members":            []map[string]any{},
+			}
+			byGroup[key] = g
+			order = append(order, key)
+		}
+
+		g["activeConnections"] = g["activeConnections"].(int64) + r["activeConnections"].(int64)
+		if r["isIgnoreVpn"].(bool) {
+			g["ignoreVpnMembers"] = g["ignoreVpnMembers"].(int) + 1
+		} else {
+			g["isIgnoreVpn"] = false
+		}
+		if u := r["updatedAt"].(string); u > g["updatedAt"].(string) {
+			g["updatedAt"] = u
+		}
+		g["members"] = append(g["members"].([]map[string]any), r)
+	}
+
+	out := make([]map[string]any, 0, len(order))
+	for _, key := range order {
+		g := byGroup[key]
+		SortByActiveConnections(g["members"].([]map[string]any))
+		out = append(out, g)
+	}
+	SortByActiveConnections(out)
+	return out
+}
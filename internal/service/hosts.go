@@ -0,0 +1,202 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/domain"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// topDomainsPerHost — сколько доменов показывать у каждого клиента.
+const topDomainsPerHost = 5
+
+// Hosts собирает LAN-клиентов роутера: аренды DHCP плюс src-адреса из соединений
+// и агрегатов трафика (только частные адреса — внешние источники входящих соединений
+// клиентами не считаются). Трафик и домены — начиная с from; withConns добавляет
+// текущие соединения каждого клиента (по последнему тику коллектора, см. Connections).
+func (c *CollectService) Hosts(ctx context.Context, bucket storage.Bucket, from time.Time, withConns bool) ([]domain.HostConnection, error) {
+	s := c.connections
+	router := s.router
+
+	conns, err := c.Connections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	leaseRows, err := s.mt.DHCPLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ignoreRows []map[string]string
+	for _, fam := range s.families() {
+		rows, _, err := s.addressList(ctx, fam, s.ignoreLanToVpnListName)
+		if err != nil {
+			return nil, err
+		}
+		ignoreRows = append(ignoreRows, rows...)
+	}
+	traffic, err := c.repo.FindHostTraffic(ctx, router, bucket, from)
+	if err != nil {
+		return nil, err
+	}
+	top, err := c.repo.FindTopDomainsByHost(ctx, router, from, topDomainsPerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := map[string]*domain.HostConnection{}
+	get := func(ip string) *domain.HostConnection {
+		h := hosts[ip]
+		if h == nil {
+			h = &domain.HostConnection{Router: router, SrcIP: ip}
+			hosts[ip] = h
+		}
+		return h
+	}
+
+	for _, r := range leaseRows {
+		ip := r["active-address"]
+		if ip == "" {
+			ip = r["address"]
+		}
+		if ip == "" {
+			continue
+		}
+		h := get(canonIP(ip))
+		h.HostName = r["active-host-name"]
+		if h.HostName == "" {
+			h.HostName = r["host-name"]
+		}
+		if h.HostName == "" {
+			h.HostName = r["comment"]
+		}
+		h.MAC = r["active-mac-address"]
+		if h.MAC == "" {
+			h.MAC = r["mac-address"]
+		}
+	}
+
+	// IPv6-клиенты обычно с глобальными адресами: своими считаем и тех, кого знает DHCPv6
+	for _, cn := range conns {
+		if hosts[cn.SrcIP] == nil && !isLANAddr(cn.SrcIP) && cn.HostName == "" {
+			continue
+		}
+		h := get(cn.SrcIP)
+		h.ActiveConnections++
+		if h.HostName == "" {
+			h.HostName = cn.HostName
+		}
+		if withConns {
+			h.Connections = append(h.Connections, cn)
+		}
+	}
+
+	for ip, t := range traffic {
+		if hosts[ip] == nil && !isLANAddr(ip) {
+			// глобальный IPv6 без активных соединений — не отличить от внешнего источника
+			continue
+		}
+		h := get(ip)
+		h.UpBytes = t.OrigBytes
+		h.DownBytes = t.ReplBytes
+	}
+
+	ignored := enabledPrefixes(ignoreRows)
+
+	out := make([]domain.HostConnection, 0, len(hosts))
+	for ip, h := range hosts {
+		h.TopDomains = top[ip]
+		if h.TopDomains == nil {
+			h.TopDomains = []domain.HostDomain{}
+		}
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			for _, p := range ignored {
+				if p.Contains(addr) {
+					h.IsIgnoreLanToVpn = true
+					break
+				}
+			}
+		}
+		out = append(out, *h)
+	}
+	return out, nil
+}
+
+// isLANAddr: частный или link-local адрес.
+func isLANAddr(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && (addr.IsPrivate() || addr.IsLinkLocalUnicast())
+}
+
+// enabledPrefixes — включённые записи address-list как префиксы (адрес без маски — /32 или /128).
+func enabledPrefixes(rows []map[string]string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, r := range rows {
+		if r["disabled"] == "true" || r["disabled"] == "yes" {
+			continue
+		}
+		a := strings.TrimSpace(r["address"])
+		if p, err := netip.ParsePrefix(a); err == nil {
+			out = append(out, p)
+		} else if addr, err := netip.ParseAddr(a); err == nil {
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return out
+}
+
+// SortHosts сортирует клиентов по by: bytes (up+down), up, down, connections, name, ip.
+func SortHosts(hosts []domain.HostConnection, by string, desc bool) error {
+	var less func(a, b domain.HostConnection) int
+	switch by {
+	case "bytes":
+		less = func(a, b domain.HostConnection) int {
+			return cmp.Compare(a.UpBytes+a.DownBytes, b.UpBytes+b.DownBytes)
+		}
+	case "up":
+		less = func(a, b domain.HostConnection) int { return cmp.Compare(a.UpBytes, b.UpBytes) }
+	case "down":
+		less = func(a, b domain.HostConnection) int { return cmp.Compare(a.DownBytes, b.DownBytes) }
+	case "connections":
+		less = func(a, b domain.HostConnection) int { return cmp.Compare(a.ActiveConnections, b.ActiveConnections) }
+	case "name":
+		less = func(a, b domain.HostConnection) int {
+			return cmp.Compare(strings.ToLower(a.HostName), strings.ToLower(b.HostName))
+		}
+	case "ip":
+		less = func(a, b domain.HostConnection) int { return compareIP(a.SrcIP, b.SrcIP) }
+	default:
+		return fmt.Errorf("unknown sort %q (want bytes, up, down, connections, name or ip)", by)
+	}
+
+	slices.SortStableFunc(hosts, func(a, b domain.HostConnection) int {
+		n := less(a, b)
+		if desc {
+			n = -n
+		}
+		if n == 0 {
+			// при равенстве — стабильный порядок по адресу и роутеру
+			n = cmp.Or(compareIP(a.SrcIP, b.SrcIP), cmp.Compare(a.Router, b.Router))
+		}
+		return n
+	})
+	return nil
+}
+
+func compareIP(a, b string) int {
+	x, errX := netip.ParseAddr(a)
+	y, errY := netip.ParseAddr(b)
+	if errX != nil || errY != nil {
+		return cmp.Compare(a, b)
+	}
+	return x.Compare(y)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+)
+
+// MatchMode — как записи ignore-VPN листа сопоставляются с доменом.
+type MatchMode string
+
+const (
+	// MatchExact — только точное совпадение (как раньше).
+	MatchExact MatchMode = "exact"
+	// MatchSuffix — запись example.com покрывает и example.com, и cdn.example.com.
+	MatchSuffix MatchMode = "suffix"
+	// MatchWildcard — как suffix, плюс шаблоны в записях: *.example.com, cdn?.example.com.
+	MatchWildcard MatchMode = "wildcard"
+)
+
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch m := MatchMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return MatchExact, nil
+	case MatchExact, MatchSuffix, MatchWildcard:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown ignore-vpn match mode %q (want exact, suffix or wildcard)", s)
+}
+
+// UseIgnoreVPNMatch задаёт режим сопоставления доменов с ignore-VPN листом.
+func (s *ConnectionsService) UseIgnoreVPNMatch(mode MatchMode) { s.ignoreVPNMatch = mode }
+
+// matchIgnoreEntry возвращает запись листа, которая покрывает domain, или "".
+// Точное совпадение важнее, дальше — самая длинная (самая узкая) запись.
+// Адрес вместо домена сравнивается с адресами листа в каноническом виде, а в режимах
+// suffix и wildcard покрывается и подсетью, как поддомен — доменом.
+func matchIgnoreEntry(mode MatchMode, entries map[string]bool, domain string) string {
+	if entries[domain] {
+		return domain
+	}
+	if a, err := netip.ParseAddr(strings.TrimSpace(domain)); err == nil {
+		return matchIgnoreAddr(mode, entries, a.Unmap())
+	}
+	if mode != MatchSuffix && mode != MatchWildcard {
+		return ""
+	}
+
+	d := normDNSName(domain)
+	best := ""
+	for e := range entries {
+		entry := normDNSName(e)
+		ok := false
+		switch {
+		case entry == "":
+		case strings.ContainsAny(entry, "*?["):
+			if mode == MatchWildcard {
+				ok, _ = path.Match(entry, d)
+			}
+		default:
+			ok = d == entry || strings.HasSuffix(d, "."+entry)
+		}
+		if ok && (len(e) > len(best) || len(e) == len(best) && e < best) {
+			best = e
+		}
+	}
+	return best
+}
+
+// matchIgnoreAddr — matchIgnoreEntry для адреса: самая узкая подсеть листа, которая его содержит
+// (отдельный адрес — подсеть /32 или /128).
+func matchIgnoreAddr(mode MatchMode, entries map[string]bool, a netip.Addr) string {
+	best, bestBits := "", -1
+	for e := range entries {
+		p, err := netip.ParsePrefix(strings.TrimSpace(e))
+		if err != nil {
+			ea, err := netip.ParseAddr(strings.TrimSpace(e))
+			if err != nil {
+				continue
+			}
+			p = netip.PrefixFrom(ea.Unmap(), ea.Unmap().BitLen())
+		}
+		if !p.Contains(a) || !p.IsSingleIP() && mode != MatchSuffix && mode != MatchWildcard {
+			continue
+		}
+		if p.Bits() > bestBits || p.Bits() == bestBits && e < best {
+			best, bestBits = e, p.Bits()
+		}
+	}
+	return best
+}
@@ -0,0 +1,52 @@
+package service
+
+import "testing"
+
+func TestMatchIgnoreEntry(t *testing.T) {
+	entries := map[string]bool{
+		"example.com":     true,
+		"cdn.example.com": true,
+		"*.video.net":     true,
+		"Upper.ORG.":      true,
+		"10.0.0.0/8":      true,
+		"10.1.0.0/16":     true,
+		"192.168.1.5":     true,
+		"2001:DB8::0001":  true,
+		"2001:db8:1::/48": true,
+	}
+	for _, tc := range []struct {
+		mode   MatchMode
+		domain string
+		want   string
+	}{
+		// домены
+		{MatchExact, "example.com", "example.com"},
+		{MatchExact, "api.example.com", ""},
+		{MatchSuffix, "api.example.com", "example.com"},
+		{MatchSuffix, "x.cdn.example.com", "cdn.example.com"},
+		{MatchSuffix, "badexample.com", ""},
+		{MatchSuffix, "upper.org", "Upper.ORG."},
+		{MatchSuffix, "a.video.net", ""},
+		{MatchWildcard, "a.video.net", "*.video.net"},
+		{MatchWildcard, "video.net", ""},
+
+		// адреса и подсети
+		{MatchExact, "192.168.1.5", "192.168.1.5"},
+		{MatchExact, "10.1.2.3", ""},
+		{MatchSuffix, "10.1.2.3", "10.1.0.0/16"},
+		{MatchSuffix, "10.200.0.1", "10.0.0.0/8"},
+		{MatchSuffix, "11.0.0.1", ""},
+		{MatchSuffix, "::ffff:10.1.2.3", "10.1.0.0/16"},
+
+		// IPv6 в каноническом виде
+		{MatchExact, "2001:db8::1", "2001:DB8::0001"},
+		{MatchExact, "2001:0db8:0000::1", "2001:DB8::0001"},
+		{MatchExact, "2001:db8:1::5", ""},
+		{MatchWildcard, "2001:db8:1::5", "2001:db8:1::/48"},
+		{MatchWildcard, "2001:db8:2::5", ""},
+	} {
+		if got := matchIgnoreEntry(tc.mode, entries, tc.domain); got != tc.want {
+			t.Errorf("%s %s: got %q, want %q", tc.mode, tc.domain, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
+)
+
+var (
+	ErrUnknownList = errors.New("unknown address list")
+	ErrBadChange   = errors.New("bad change")
+)
+
+// UseAddressLists разрешает управлять через API ещё и этими листами (кроме ignore-VPN и ignoreLanToVpn).
+func (s *ConnectionsService) UseAddressLists(names []string) {
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" && s.checkManagedList(n) != nil {
+			s.extraLists = append(s.extraLists, n)
+		}
+	}
+}
+
+// EntryChange — изменение одной записи address-листа.
+type EntryChange struct {
+	Address string
+	Op      string // enable (добавить или включить) | disable | remove
+	Opts    mikrotik.EntryOptions
+}
+
+// EntryResult — что стало с записью в листе одного семейства.
+type EntryResult struct {
+	Router  string `json:"router"`
+	Family  string `json:"family"`
+	Address string `json:"address"`
+	Op      string `json:"op"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ChangesFor — одна и та же операция над несколькими адресами.
+func ChangesFor(addresses []string, op string, opts mikrotik.EntryOptions) []EntryChange {
+	out := make([]EntryChange, 0, len(addresses))
+	for _, a := range addresses {
+		out = append(out, EntryChange{Address: a, Op: op, Opts: opts})
+	}
+	return out
+}
+
+// ChangeList применяет изменения к управляемому листу: снимок перед записью, актуальные .id
+// с роутера и запись в журнал по каждой записи. Адреса и подсети меняются в листе своего семейства,
+// домены — во всех (роутер резолвит домен в A для /ip и в AAAA для /ipv6).
+// Ошибка одной записи не останавливает остальные; возвращается первая.
+func (s *ConnectionsService) ChangeList(ctx context.Context, list string, changes []EntryChange) ([]EntryResult, error) {
+	if err := s.checkManagedList(list); err != nil {
+		return nil, err
+	}
+	changes, err := dedupeChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second+time.Duration(len(changes))*200*time.Millisecond)
+	defer cancel()
+
+	s.snapshotBeforeWrite(ctx, list)
+
+	var results []EntryResult
+	var firstErr error
+	for _, fam := range s.families() {
+		var famChanges []EntryChange
+		for _, c := range changes {
+			if !isAddress(c.Address) || mikrotik.FamilyOf(c.Address) == fam {
+				famChanges = append(famChanges, c)
+			}
+		}
+		if len(famChanges) == 0 {
+			continue
+		}
+		res, err := s.changeFamily(ctx, fam, list, famChanges)
+		results = append(results, res...)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+func (s *ConnectionsService) changeFamily(ctx context.Context, fam mikrotik.Family, list string, changes []EntryChange) ([]EntryResult, error) {
+	// лист читаем с роутера (нужны актуальные .id), кэш после записи сбрасываем
+	defer s.lists.invalidate(listKey{fam: fam, name: list})
+
+	rows, readErr := s.mt.AddressListIgnoreVPN(ctx, fam, list)
+	byKey := map[string]map[string]string{}
+	for _, r := range rows {
+		a := r["address"]
+		if a == "" {
+			continue
+		}
+		// адрес, добавленный вручную, важнее такого же адреса из резолва домена
+		if _, ok := byKey[addressKey(a)]; ok && autoEntry(r) {
+			continue
+		}
+		byKey[addressKey(a)] = r
+	}
+
+	results := make([]EntryResult, 0, len(changes))
+	var firstErr error
+	for _, c := range changes {
+		row := byKey[addressKey(c.Address)]
+		res := EntryResult{Router: s.router, Family: string(fam), Address: c.Address, Op: c.Op,
+			Before: listState(row), After: targetState(row, c.Op)}
+
+		err := readErr
+		if err == nil {
+			err = s.applyEntry(ctx, fam, list, c.Address, row, c.Op, c.Opts)
+		} else {
+			res.Before = "" // лист не прочитан — прежнее состояние неизвестно
+		}
+		s.recordChange(ctx, fam, list, c.Address, res.Before, res.After, c.Opts, err)
+		if err != nil {
+			res.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		results = append(results, res)
+	}
+	return results, firstErr
+}
+
+// dedupeChanges проверяет изменения и убирает повторы; один адрес с разными операциями — ошибка.
+func dedupeChanges(changes []EntryChange) ([]EntryChange, error) {
+	out := make([]EntryChange, 0, len(changes))
+	seen := map[string]string{}
+	for _, c := range changes {
+		c.Address = strings.TrimSpace(c.Address)
+		if c.Address == "" {
+			continue
+		}
+		switch c.Op {
+		case OpEnable, OpDisable, OpRemove:
+		default:
+			return nil, fmt.Errorf("%w: %s: unknown op %q (enable, disable, remove)", ErrBadChange, c.Address, c.Op)
+		}
+		if c.Opts.Timeout > 0 && c.Op != OpEnable {
+			return nil, fmt.Errorf("%w: %s: timeout only applies to enable", ErrBadChange, c.Address)
+		}
+
+		k := addressKey(c.Address)
+		if op, ok := seen[k]; ok {
+			if op != c.Op {
+				return nil, fmt.Errorf("%w: %s: both %s and %s requested", ErrBadChange, c.Address, op, c.Op)
+			}
+			continue
+		}
+		seen[k] = c.Op
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// addressKey — адрес записи для сравнения: адреса и подсети в каноническом виде
+// (хост-префикс /32, /128 — как адрес), домены — без учёта регистра.
+func addressKey(address string) string {
+	address = strings.TrimSpace(address)
+	if p, err := netip.ParsePrefix(address); err == nil {
+		if p.IsSingleIP() {
+			return p.Addr().Unmap().String()
+		}
+		return p.Masked().String()
+	}
+	if a, err := netip.ParseAddr(address); err == nil {
+		return a.Unmap().String()
+	}
+	return strings.ToLower(address)
+}
+
+// AddressListEntry — запись address-листа для API.
+type AddressListEntry struct {
+	Router       string `json:"router,omitempty"`
+	List         string `json:"list"`
+	Family       string `json:"family"`
+	ID           string `json:"id"`
+	Address      string `json:"address"`
+	Enabled      bool   `json:"enabled"`
+	Dynamic      bool   `json:"dynamic"`
+	Comment      string `json:"comment,omitempty"`
+	Timeout      string `json:"timeout,omitempty"` // сколько осталось до удаления временной записи
+	CreationTime string `json:"creationTime,omitempty"`
+
+	ListUpdatedAt time.Time `json:"listUpdatedAt"` // когда лист был прочитан с роутера
+}
+
+// ListFilter — пустые поля не фильтруют.
+type ListFilter struct {
+	Find     string // подстрока адреса или комментария
+	State    string // enabled | disabled
+	Resolved bool   // показывать и адреса, которые роутер добавил сам при резолве доменов
+}
+
+// AddressListEntries — записи управляемого листа обоих семейств (из кэша, как остальные чтения листов).
+func (s *ConnectionsService) AddressListEntries(ctx context.Context, list string, f ListFilter) ([]AddressListEntry, error) {
+	if err := s.checkManagedList(list); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	find := strings.ToLower(strings.TrimSpace(f.Find))
+	out := []AddressListEntry{}
+	for _, fam := range s.families() {
+		rows, at, err := s.addressList(ctx, fam, list)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if r["address"] == "" || (!f.Resolved && autoEntry(r)) {
+				continue
+			}
+			state := listState(r)
+			if f.State != "" && state != f.State {
+				continue
+			}
+			if find != "" &&
+				!strings.Contains(strings.ToLower(r["address"]), find) &&
+				!strings.Contains(strings.ToLower(r["comment"]), find) {
+				continue
+			}
+			out = append(out, AddressListEntry{
+				Router:       s.router,
+				List:         list,
+				Family:       string(fam),
+				ID:           r[".id"],
+				Address:      r["address"],
+				Enabled:      state == storage.StateEnabled,
+				Dynamic:      r["dynamic"] == "true",
+				Comment:      r["comment"],
+				Timeout:      r["timeout"],
+				CreationTime: r["creation-time"],
+
+				ListUpdatedAt: at,
+			})
+		}
+	}
+	return out, nil
+}
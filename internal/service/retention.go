@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"mikrotik-parser-go/internal/storage"
+)
+
+// RetentionPolicy — сколько хранить данные; 0 — хранить всегда.
+type RetentionPolicy struct {
+	History   time.Duration // закрытые сессии (connections)
+	Counts    time.Duration // счётчики доменов/адресов, которые перестали обновляться
+	DNS       time.Duration // связи адрес -> домен после истечения в кэше роутера
+	Audit     time.Duration // журнал изменений address-листов
+	Snapshots time.Duration // снимки address-листов (последний снимок листа хранится всегда)
+
+	// агрегаты рядов по гранулярности
+	Rollups map[storage.Bucket]time.Duration
+
+	Batch int // строк за один delete
+}
+
+// RetentionService периодически чистит базу по RetentionPolicy и возвращает место файлу.
+type RetentionService struct {
+	repo     *storage.Sqlite
+	policy   RetentionPolicy
+	interval time.Duration
+}
+
+func NewRetentionService(repo *storage.Sqlite, policy RetentionPolicy, interval time.Duration) *RetentionService {
+	return &RetentionService{repo: repo, policy: policy, interval: interval}
+}
+
+func (s *RetentionService) Run(ctx context.Context) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := s.PruneOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Println("retention:", err)
+			}
+		}
+	}
+}
+
+// PruneOnce удаляет устаревшие записи и возвращает число удалённых строк по группам.
+func (s *RetentionService) PruneOnce(ctx context.Context) (map[string]int64, error) {
+	now := time.Now()
+	deleted := map[string]int64{}
+
+	if s.policy.History > 0 {
+		n, err := s.repo.PruneHistory(ctx, now.Add(-s.policy.History), s.policy.Batch)
+		deleted["history"] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	if s.policy.Counts > 0 {
+		n, err := s.repo.PruneCounts(ctx, now.Add(-s.policy.Counts), s.policy.Batch)
+		deleted["counts"] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if s.policy.DNS > 0 {
+		n, err := s.repo.PruneDNSMappings(ctx, now.Add(-s.policy.DNS), s.policy.Batch)
+		deleted["dns"] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if s.policy.Audit > 0 {
+		n, err := s.repo.PruneAudit(ctx, now.Add(-s.policy.Audit), s.policy.Batch)
+		deleted["audit"] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if s.policy.Snapshots > 0 {
+		n, err := s.repo.PruneSnapshots(ctx, now.Add(-s.policy.Snapshots), s.policy.Batch)
+		deleted["snapshots"] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	// истёкшие сессии SPA — всегда
+	n, err := s.repo.PruneSessions(ctx, now)
+	deleted["sessions"] = n
+	if err != nil {
+		return deleted, err
+	}
+
+	for _, b := range storage.Buckets {
+		keep := s.policy.Rollups[b]
+		if keep <= 0 {
+			continue
+		}
+		n, err := s.repo.PruneRollups(ctx, b, now.Add(-keep), s.policy.Batch)
+		deleted["rollups_"+string(b)] = n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	// освобождаем до 1000 страниц за раз, чтобы не держать соединение долго
+	return deleted, s.repo.Compact(ctx, 1000)
+}
+
+func (s *RetentionService) Stats(ctx context.Context) (storage.DBStats, error) {
+	return s.repo.Stats(ctx)
+}
+
+func (s *RetentionService) Policy() RetentionPolicy { return s.policy }
@@ -0,0 +1,11 @@
+package service
+
+import "mikrotik-parser-go/internal/mikrotik"
+
+// Router — клиент и сервисы одного роутера.
+type Router struct {
+	Name        string
+	Client      mikrotik.API
+	Connections *ConnectionsService
+	Collect     *CollectService
+}
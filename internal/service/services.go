@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"mikrotik-parser-go/internal/catalog"
+	"mikrotik-parser-go/internal/mikrotik"
+)
+
+// ServicePrefix — элемент списка PostDnsToIgnoreList, означающий сервис из каталога целиком.
+const ServicePrefix = "service:"
+
+// UseCatalog подключает каталог сервисов (ServiceTraffic и "service:Имя" в PostDnsToIgnoreList).
+func (s *ConnectionsService) UseCatalog(c *catalog.Catalog) { s.catalog = c }
+
+// expandServices заменяет "service:Имя" на домены и подсети сервиса.
+func (s *ConnectionsService) expandServices(items []string) ([]string, error) {
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		name, ok := strings.CutPrefix(it, ServicePrefix)
+		if !ok {
+			out = append(out, it)
+			continue
+		}
+		if s.catalog == nil {
+			return nil, fmt.Errorf("service catalogue is not configured")
+		}
+		svc, ok := s.catalog.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown service %q", name)
+		}
+		out = append(out, svc.Domains...)
+		for _, p := range svc.CIDRs {
+			out = append(out, p.String())
+		}
+	}
+	return out, nil
+}
+
+// isAddress: адрес или подсеть, а не домен.
+func isAddress(s string) bool {
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// ServiceTraffic — живой трафик сервиса из каталога на одном роутере.
+type ServiceTraffic struct {
+	Router            string   `json:"router"`
+	Name              string   `json:"name"`
+	ActiveConnections int64    `json:"activeConnections"`
+	Hosts             int      `json:"hosts"`     // LAN-клиентов с соединениями
+	Domains           []string `json:"domains"`   // имена, увиденные в соединениях
+	OrigBytes         int64    `json:"origBytes"` // счётчики открытых соединений
+	ReplBytes         int64    `json:"replBytes"`
+	IsIgnoreVPN       bool     `json:"isIgnoreVpn"` // все домены и подсети сервиса включены в ignore-VPN листе
+
+	IgnoreVPNUpdatedAt time.Time `json:"ignoreVpnUpdatedAt"` // когда лист был прочитан с роутера
+}
+
+// ServiceTraffic группирует соединения последнего тика коллектора (см. Connections)
+// по сервисам каталога; соединения, не попавшие ни в один сервис, не показываются.
+func (c *CollectService) ServiceTraffic(ctx context.Context) ([]ServiceTraffic, error) {
+	s := c.connections
+	if s.catalog == nil {
+		return []ServiceTraffic{}, nil
+	}
+
+	conns, err := c.Connections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 6*time.Second)
+	defer cancel()
+
+	enabled := map[mikrotik.Family]map[string]bool{}
+	for _, fam := range s.families() {
+		rows, _, err := s.addressList(ctx, fam, s.ignoreVPNListName)
+		if err != nil {
+			return nil, err
+		}
+		enabled[fam] = map[string]bool{}
+		for _, r := range rows {
+			if r["disabled"] != "true" && r["disabled"] != "yes" {
+				enabled[fam][r["address"]] = true
+			}
+		}
+	}
+
+	type agg struct {
+		ServiceTraffic
+		hosts   map[string]bool
+		domains map[string]bool
+	}
+	byName := map[string]*agg{}
+	for _, cn := range conns {
+		name := s.catalog.Match(cn.DstDNS, cn.DstIP)
+		if name == "" {
+			continue
+		}
+		a := byName[name]
+		if a == nil {
+			a = &agg{
+				ServiceTraffic: ServiceTraffic{Router: s.router, Name: name, IgnoreVPNUpdatedAt: s.IgnoreVPNUpdatedAt()},
+				hosts:          map[string]bool{},
+				domains:        map[string]bool{},
+			}
+			byName[name] = a
+		}
+		a.ActiveConnections++
+		a.OrigBytes += cn.OrigBytes
+		a.ReplBytes += cn.ReplBytes
+		a.hosts[cn.SrcIP] = true
+		if cn.DstDNS != "" {
+			a.domains[cn.DstDNS] = true
+		}
+	}
+
+	out := make([]ServiceTraffic, 0, len(byName))
+	for name, a := range byName {
+		a.Hosts = len(a.hosts)
+		a.Domains = make([]string, 0, len(a.domains))
+		for d := range a.domains {
+			a.Domains = append(a.Domains, d)
+		}
+		sort.Strings(a.Domains)
+
+		svc, _ := s.catalog.Get(name)
+		a.IsIgnoreVPN = true
+		for _, d := range svc.Domains {
+			if !enabled[mikrotik.IPv4][d] {
+				a.IsIgnoreVPN = false
+			}
+		}
+		for _, p := range svc.CIDRs {
+			if fam := mikrotik.FamilyOf(p.String()); enabled[fam] != nil && !enabled[fam][p.String()] {
+				a.IsIgnoreVPN = false
+			}
+		}
+		out = append(out, a.ServiceTraffic)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].OrigBytes+out[i].ReplBytes > out[j].OrigBytes+out[j].ReplBytes
+	})
+	return out, nil
+}
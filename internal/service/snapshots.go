@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// Причины снимков address-листов.
+const (
+	SnapshotPeriodic      = "periodic"
+	SnapshotBeforeWrite   = "before-write"
+	SnapshotBeforeRestore = "before-restore"
+	SnapshotManual        = "manual"
+)
+
+// UseSnapshots включает снимки address-листов (периодические — через RunSnapshots, и перед каждой записью).
+func (s *ConnectionsService) UseSnapshots(repo *storage.Sqlite) { s.snapshots = repo }
+
+// ManagedLists — address-листы, которые меняет сервис: ignore-VPN, ignoreLanToVpn и листы из UseAddressLists.
+func (s *ConnectionsService) ManagedLists() []string {
+	return append([]string{s.ignoreVPNListName, s.ignoreLanToVpnListName}, s.extraLists...)
+}
+
+func (s *ConnectionsService) checkManagedList(list string) error {
+	for _, l := range s.ManagedLists() {
+		if l == list {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q", ErrUnknownList, list)
+}
+
+// RunSnapshots снимает управляемые листы сразу и затем каждые interval; снимок без изменений не сохраняется.
+func (s *ConnectionsService) RunSnapshots(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		for _, list := range s.ManagedLists() {
+			if _, err := s.TakeSnapshot(ctx, list, SnapshotPeriodic); err != nil && ctx.Err() == nil {
+				log.Printf("snapshot %s/%s: %v", s.router, list, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// entryKey — запись листа в пределах семейства; address — addressKey адреса,
+// чтобы "10.0.0.1/32" в снимке и "10.0.0.1" на роутере считались одной записью.
+type entryKey struct {
+	fam     mikrotik.Family
+	address string
+}
+
+func keyOf(fam mikrotik.Family, address string) entryKey {
+	return entryKey{fam: fam, address: addressKey(address)}
+}
+
+// timedEntry — временная запись с timeout: динамическая, но добавлена через API и истечёт сама.
+func timedEntry(r map[string]string) bool {
+	return r["dynamic"] == "true" && r["timeout"] != ""
+}
+
+// readList читает лист обоих семейств прямо с роутера (нужны актуальные .id): entries — статические
+// записи для снимка, rows — они же плюс временные записи, которые занимают адрес при восстановлении.
+func (s *ConnectionsService) readList(ctx context.Context, list string) ([]storage.SnapshotEntry, map[entryKey]map[string]string, error) {
+	var entries []storage.SnapshotEntry
+	rows := map[entryKey]map[string]string{}
+	for _, fam := range s.families() {
+		addresses, err := s.mt.AddressListIgnoreVPN(ctx, fam, list)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range addresses {
+			// динамические записи — результат резолва доменов (их восстанавливает сам роутер)
+			// или временные с timeout (истекают сами) — в снимок не попадают
+			if autoEntry(r) || r["address"] == "" {
+				continue
+			}
+			rows[keyOf(fam, r["address"])] = r
+			if timedEntry(r) {
+				continue
+			}
+			entries = append(entries, storage.SnapshotEntry{
+				Family:   string(fam),
+				Address:  r["address"],
+				Disabled: listState(r) == storage.StateDisabled,
+				Comment:  r["comment"],
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Family != entries[j].Family {
+			return entries[i].Family < entries[j].Family
+		}
+		return entries[i].Address < entries[j].Address
+	})
+	return entries, rows, nil
+}
+
+// TakeSnapshot снимает лист; периодический снимок, совпадающий с предыдущим, не сохраняется.
+func (s *ConnectionsService) TakeSnapshot(ctx context.Context, list, reason string) (storage.ListSnapshot, error) {
+	if s.snapshots == nil {
+		return storage.ListSnapshot{}, fmt.Errorf("snapshots are not configured")
+	}
+	if err := s.checkManagedList(list); err != nil {
+		return storage.ListSnapshot{}, err
+	}
+
+	entries, _, err := s.readList(ctx, list)
+	if err != nil {
+		return storage.ListSnapshot{}, err
+	}
+	snap := storage.ListSnapshot{Router: s.router, List: list, Reason: reason, Entries: entries}
+	if p, ok := PrincipalFrom(ctx); ok {
+		snap.Actor = p.Name
+	}
+	snap, _, err = s.snapshots.InsertListSnapshot(ctx, snap, reason == SnapshotPeriodic)
+	return snap, err
+}
+
+// snapshotBeforeWrite снимает лист перед изменением; без снимка запись всё равно выполняется.
+func (s *ConnectionsService) snapshotBeforeWrite(ctx context.Context, list string) {
+	if s.snapshots == nil {
+		return
+	}
+	if _, err := s.TakeSnapshot(ctx, list, SnapshotBeforeWrite); err != nil {
+		log.Printf("snapshot %s/%s before write: %v", s.router, list, err)
+	}
+}
+
+// Snapshots — снимки роутера без записей, новые сверху; list == "" — все листы.
+func (s *ConnectionsService) Snapshots(ctx context.Context, list string, limit int) ([]storage.ListSnapshot, error) {
+	if s.snapshots == nil {
+		return nil, nil
+	}
+	return s.snapshots.FindListSnapshots(ctx, s.router, list, limit)
+}
+
+// Snapshot — снимок с записями; снимок другого роутера — storage.ErrNotFound.
+func (s *ConnectionsService) Snapshot(ctx context.Context, id int64) (storage.ListSnapshot, error) {
+	if s.snapshots == nil {
+		return storage.ListSnapshot{}, storage.ErrNotFound
+	}
+	snap, err := s.snapshots.GetListSnapshot(ctx, id)
+	if err != nil {
+		return storage.ListSnapshot{}, err
+	}
+	if snap.Router != s.router {
+		return storage.ListSnapshot{}, storage.ErrNotFound
+	}
+	return snap, nil
+}
+
+// Операции восстановления записи.
+const (
+	OpAdd     = "add"
+	OpEnable  = "enable"
+	OpDisable = "disable"
+	OpRemove  = "remove"
+	OpComment = "comment" // состояние совпадает, меняется только комментарий
+	OpReplace = "replace" // временная запись заменяется постоянной из снимка (remove + add)
+)
+
+// SnapshotChange — запись, которая отличается в живом листе и в снимке.
+type SnapshotChange struct {
+	Family   string `json:"family"`
+	Address  string `json:"address"`
+	Live     string `json:"live"`     // absent | enabled | disabled
+	Snapshot string `json:"snapshot"` // состояние после восстановления
+	Op       string `json:"op"`       // add | enable | disable | remove | comment | replace
+
+	LiveComment string `json:"liveComment,omitempty"`
+	Comment     string `json:"comment,omitempty"` // комментарий после восстановления (из снимка)
+
+	Error string `json:"error,omitempty"`
+}
+
+type SnapshotDiff struct {
+	Snapshot  storage.ListSnapshot `json:"snapshot"`
+	Changes   []SnapshotChange     `json:"changes"`
+	Unchanged int                  `json:"unchanged"`
+}
+
+// SnapshotDiff сравнивает снимок с живым листом роутера.
+func (s *ConnectionsService) SnapshotDiff(ctx context.Context, id int64) (SnapshotDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	snap, err := s.Snapshot(ctx, id)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+	d, _, err := s.diffSnapshot(ctx, snap)
+	return d, err
+}
+
+func (s *ConnectionsService) diffSnapshot(ctx context.Context, snap storage.ListSnapshot) (SnapshotDiff, map[entryKey]map[string]string, error) {
+	_, rows, err := s.readList(ctx, snap.List)
+	if err != nil {
+		return SnapshotDiff{}, nil, err
+	}
+
+	d := SnapshotDiff{Snapshot: snap, Changes: []SnapshotChange{}}
+	d.Snapshot.Entries = nil
+
+	inSnapshot := map[entryKey]bool{}
+	for _, e := range snap.Entries {
+		k := keyOf(mikrotik.Family(e.Family), e.Address)
+		inSnapshot[k] = true
+
+		want := storage.StateEnabled
+		if e.Disabled {
+			want = storage.StateDisabled
+		}
+		row := rows[k]
+		c := SnapshotChange{Family: e.Family, Address: e.Address, Live: listState(row), Snapshot: want,
+			LiveComment: row["comment"], Comment: e.Comment}
+		switch {
+		case row == nil:
+			c.Op = OpAdd
+		case timedEntry(row):
+			// add поверх временной записи роутер отвергнет ("already have such entry")
+			c.Op = OpReplace
+		case c.Live != want && e.Disabled:
+			c.Op = OpDisable
+		case c.Live != want:
+			c.Op = OpEnable
+		case c.LiveComment != c.Comment:
+			c.Op = OpComment
+		default:
+			d.Unchanged++
+			continue
+		}
+		d.Changes = append(d.Changes, c)
+	}
+	for k, row := range rows {
+		// временные записи не из снимка не трогаем — они истекут сами
+		if inSnapshot[k] || timedEntry(row) {
+			continue
+		}
+		d.Changes = append(d.Changes, SnapshotChange{
+			Family: string(k.fam), Address: row["address"], Live: listState(row), Snapshot: storage.StateAbsent, Op: OpRemove,
+			LiveComment: row["comment"],
+		})
+	}
+
+	sort.Slice(d.Changes, func(i, j int) bool {
+		a, b := d.Changes[i], d.Changes[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		return a.Address < b.Address
+	})
+	return d, rows, nil
+}
+
+// RestoreSnapshot приводит живой лист к снимку минимальным набором add/set/remove.
+// Перед восстановлением снимается текущее состояние (before-restore) — восстановление тоже можно откатить.
+// Каждая операция пишется в журнал; на первой ошибке восстановление останавливается.
+func (s *ConnectionsService) RestoreSnapshot(ctx context.Context, id int64) (SnapshotDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	snap, err := s.Snapshot(ctx, id)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+	d, rows, err := s.diffSnapshot(ctx, snap)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+	if len(d.Changes) == 0 {
+		return d, nil
+	}
+
+	if _, err := s.TakeSnapshot(ctx, snap.List, SnapshotBeforeRestore); err != nil {
+		return d, fmt.Errorf("snapshot before restore: %w", err)
+	}
+	defer func() {
+		for _, fam := range s.families() {
+			s.lists.invalidate(listKey{fam: fam, name: snap.List})
+		}
+	}()
+
+	for i := range d.Changes {
+		c := &d.Changes[i]
+		fam := mikrotik.Family(c.Family)
+		err := s.applyChange(ctx, fam, snap.List, *c, rows[keyOf(fam, c.Address)])
+		s.recordChange(ctx, fam, snap.List, c.Address, c.Live, c.Snapshot, mikrotik.EntryOptions{Comment: &c.Comment}, err)
+		if err != nil {
+			c.Error = err.Error()
+			return d, fmt.Errorf("%s %s: %w", c.Op, c.Address, err)
+		}
+	}
+	return d, nil
+}
+
+func (s *ConnectionsService) applyChange(ctx context.Context, fam mikrotik.Family, list string, c SnapshotChange, row map[string]string) error {
+	// комментарий передаётся, только если отличается ("" — очистить)
+	var opts mikrotik.EntryOptions
+	if c.Comment != c.LiveComment {
+		opts.Comment = &c.Comment
+	}
+	switch c.Op {
+	case OpEnable, OpDisable, OpRemove:
+		return s.applyEntry(ctx, fam, list, c.Address, row, c.Op, opts)
+	case OpComment:
+		return s.mt.AddressListSetDisabled(ctx, fam, row[".id"], c.Live == storage.StateDisabled, opts)
+	case OpReplace:
+		if err := s.mt.AddressListRemove(ctx, fam, row[".id"]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.mt.AddressListAdd(ctx, fam, list, c.Address, mikrotik.EntryOptions{Comment: &c.Comment}); err != nil {
+		return err
+	}
+	if c.Snapshot != storage.StateDisabled {
+		return nil
+	}
+	// add не возвращает .id — выключенную запись находим после добавления
+	addresses, err := s.mt.AddressListIgnoreVPN(ctx, fam, list)
+	if err != nil {
+		return err
+	}
+	for _, r := range addresses {
+		if addressKey(r["address"]) == addressKey(c.Address) && r["dynamic"] != "true" {
+			return s.mt.AddressListSetDisabled(ctx, fam, r[".id"], true, mikrotik.EntryOptions{})
+		}
+	}
+	return fmt.Errorf("added entry %s not found in %s", c.Address, list)
+}
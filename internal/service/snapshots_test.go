@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	imigrate "mikrotik-parser-go/internal/migrate"
+	"mikrotik-parser-go/internal/mikrotik"
+	"mikrotik-parser-go/internal/storage"
+)
+
+func openTestDB(t *testing.T) *storage.Sqlite {
+	t.Helper()
+	dsn := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err := imigrate.Up(dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := storage.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// Diff сравнивает адреса в каноническом виде и видит изменённые комментарии; restore их возвращает.
+func TestSnapshotRestoreComments(t *testing.T) {
+	ctx := context.Background()
+	srv := startFake(t)
+	const list = "ignoreVpn"
+	srv.SetTable("/ip/firewall/address-list", []map[string]string{
+		{"list": list, "address": "10.0.0.1", "comment": "a", "disabled": "false", "dynamic": "false"},
+		{"list": list, "address": "10.0.0.2", "disabled": "false", "dynamic": "false"},
+		{"list": list, "address": "10.0.0.3", "disabled": "false", "dynamic": "false"},
+	})
+
+	s := NewConnectionsService("r1", mikrotik.New(srv.Addr(), "admin", ""), list, "ignoreLanToVpn", false)
+	s.UseSnapshots(openTestDB(t))
+	snap, err := s.TakeSnapshot(ctx, list, SnapshotManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Update("/ip/firewall/address-list", func(rows []map[string]string) []map[string]string {
+		for _, r := range rows {
+			switch r["address"] {
+			case "10.0.0.1":
+				r["comment"] = "b"
+			case "10.0.0.2":
+				r["address"] = "10.0.0.2/32" // та же запись в другом написании
+			case "10.0.0.3":
+				r["comment"] = "x"
+			}
+		}
+		return rows
+	})
+
+	d, err := s.RestoreSnapshot(ctx, snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Changes) != 2 || d.Unchanged != 1 {
+		t.Fatalf("changes = %+v, unchanged = %d; want 2 comment changes", d.Changes, d.Unchanged)
+	}
+	for _, c := range d.Changes {
+		if c.Op != OpComment {
+			t.Errorf("%s: op %q, want %q", c.Address, c.Op, OpComment)
+		}
+	}
+
+	got := map[string]string{}
+	for _, r := range srv.Table("/ip/firewall/address-list") {
+		got[r["address"]] = r["comment"]
+	}
+	if got["10.0.0.1"] != "a" || got["10.0.0.3"] != "" {
+		t.Fatalf("comments after restore = %v", got)
+	}
+
+	d, err = s.SnapshotDiff(ctx, snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Changes) != 0 {
+		t.Fatalf("diff after restore = %+v, want none", d.Changes)
+	}
+}
+
+// Адрес из снимка, который сейчас занят временной записью, восстанавливается постоянной записью;
+// временная запись не из снимка остаётся.
+func TestSnapshotRestoreOverTimedEntry(t *testing.T) {
+	ctx := context.Background()
+	srv := startFake(t)
+	const list = "ignoreVpn"
+	srv.SetTable("/ip/firewall/address-list", []map[string]string{
+		{"list": list, "address": "10.0.0.1", "comment": "static", "disabled": "false", "dynamic": "false"},
+	})
+
+	s := NewConnectionsService("r1", mikrotik.New(srv.Addr(), "admin", ""), list, "ignoreLanToVpn", false)
+	s.UseSnapshots(openTestDB(t))
+	snap, err := s.TakeSnapshot(ctx, list, SnapshotManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.SetTable("/ip/firewall/address-list", []map[string]string{
+		{"list": list, "address": "10.0.0.1", "disabled": "false", "dynamic": "true", "timeout": "1h"},
+		{"list": list, "address": "10.0.0.9", "disabled": "false", "dynamic": "true", "timeout": "1h"},
+	})
+
+	d, err := s.RestoreSnapshot(ctx, snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].Op != OpReplace {
+		t.Fatalf("changes = %+v, want one %q", d.Changes, OpReplace)
+	}
+
+	got := map[string]map[string]string{}
+	for _, r := range srv.Table("/ip/firewall/address-list") {
+		got[r["address"]] = r
+	}
+	if r := got["10.0.0.1"]; r == nil || r["dynamic"] != "false" || r["timeout"] != "" || r["comment"] != "static" {
+		t.Fatalf("10.0.0.1 after restore = %v, want static entry", r)
+	}
+	if got["10.0.0.9"] == nil {
+		t.Fatal("temporary entry outside the snapshot was removed")
+	}
+}
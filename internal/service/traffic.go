@@ -0,0 +1,54 @@
+package service
+
+import (
+	"mikrotik-parser-go/internal/domain"
+	"mikrotik-parser-go/internal/storage"
+)
+
+// trafficTracker помнит счётчики conntrack по ID соединения (семейство и .id) между тиками и считает приращения.
+// Используется только из горутины CollectService.Run.
+type trafficTracker struct {
+	last   map[string]storage.Traffic
+	primed bool
+}
+
+// deltas возвращает приращения для conns (индексы совпадают). Первый тик после
+// старта — только база: сколько соединение накачало до нас, неизвестно. Новое .id
+// позже учитывается целиком, уменьшившиеся счётчики (роутер переиспользовал .id
+// после перезагрузки) — тоже. Трафик соединения, закрытого между тиками, теряется.
+func (t *trafficTracker) deltas(conns []domain.Connection) []storage.Traffic {
+	out := make([]storage.Traffic, len(conns))
+	next := make(map[string]storage.Traffic, len(conns))
+
+	for i, c := range conns {
+		if c.ID == "" {
+			continue
+		}
+		cur := storage.Traffic{
+			OrigBytes:   c.OrigBytes,
+			ReplBytes:   c.ReplBytes,
+			OrigPackets: c.OrigPackets,
+			ReplPackets: c.ReplPackets,
+		}
+		next[c.ID] = cur
+		if !t.primed {
+			continue
+		}
+
+		prev, ok := t.last[c.ID]
+		if !ok || cur.OrigBytes < prev.OrigBytes || cur.ReplBytes < prev.ReplBytes {
+			out[i] = cur
+			continue
+		}
+		out[i] = storage.Traffic{
+			OrigBytes:   cur.OrigBytes - prev.OrigBytes,
+			ReplBytes:   cur.ReplBytes - prev.ReplBytes,
+			OrigPackets: max(cur.OrigPackets-prev.OrigPackets, 0),
+			ReplPackets: max(cur.ReplPackets-prev.ReplPackets, 0),
+		}
+	}
+
+	t.last = next
+	t.primed = true
+	return out
+}
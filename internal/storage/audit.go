@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Состояния записи address-листа в журнале.
+const (
+	StateAbsent   = "absent"
+	StateEnabled  = "enabled"
+	StateDisabled = "disabled"
+)
+
+// AuditEntry — одно изменение записи address-листа.
+type AuditEntry struct {
+	ID        int64  `json:"id"`
+	At        string `json:"at"`
+	Router    string `json:"router"`
+	Actor     string `json:"actor"`
+	Via       string `json:"via,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	List      string `json:"list"`
+	Family    string `json:"family"`
+	Address   string `json:"address"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Comment   string `json:"comment,omitempty"`
+	Timeout   string `json:"timeout,omitempty"` // временная запись: через сколько удалится
+	Result    string `json:"result"`            // ok | error
+	Error     string `json:"error,omitempty"`
+}
+
+func (p *Sqlite) InsertAudit(ctx context.Context, e AuditEntry) error {
+	if e.At == "" {
+		e.At = formatTime(time.Now())
+	}
+	_, err := p.db.ExecContext(ctx, `
+		insert into audit_log (at, router, actor, via, origin, user_agent, list_name, family, address,
+		                       before_state, after_state, comment, timeout, result, error)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.At, e.Router, e.Actor, e.Via, e.Origin, e.UserAgent, e.List, e.Family, e.Address,
+		e.Before, e.After, e.Comment, e.Timeout, e.Result, e.Error)
+	return err
+}
+
+// AuditFilter — пустые поля не фильтруют.
+type AuditFilter struct {
+	Router  string
+	From    time.Time
+	To      time.Time
+	Actor   string
+	List    string
+	Address string // подстрока
+	Result  string
+	Limit   int // 0 — последние 1000, меньше нуля — без ограничения
+}
+
+func (p *Sqlite) FindAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var from, to string
+	if !f.From.IsZero() {
+		from = formatTime(f.From)
+	}
+	if !f.To.IsZero() {
+		to = formatTime(f.To)
+	}
+	limit := f.Limit
+	if limit == 0 {
+		limit = 1000
+	} else if limit < 0 {
+		limit = -1 // в SQLite отрицательный limit — без ограничения
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		select id, at, router, actor, via, origin, user_agent, list_name, family, address,
+		       before_state, after_state, comment, timeout, result, error
+		  from audit_log
+		 where (? = '' or router = ?)
+		   and (? = '' or at >= ?)
+		   and (? = '' or at <= ?)
+		   and (? = '' or actor = ?)
+		   and (? = '' or list_name = ?)
+		   and (? = '' or lower(address) like '%' || lower(?) || '%')
+		   and (? = '' or result = ?)
+		 order by id desc
+		 limit ?
+	`, f.Router, f.Router, from, from, to, to, f.Actor, f.Actor, f.List, f.List,
+		f.Address, f.Address, f.Result, f.Result, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.At, &e.Router, &e.Actor, &e.Via, &e.Origin, &e.UserAgent, &e.List,
+			&e.Family, &e.Address, &e.Before, &e.After, &e.Comment, &e.Timeout, &e.Result, &e.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
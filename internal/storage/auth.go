@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotFound — записи нет.
+var ErrNotFound = errors.New("not found")
+
+// User — пользователь API.
+type User struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// APIToken — токен API без самого значения (хранится только хэш).
+type APIToken struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"userId"`
+	UserName   string `json:"user"`
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt,omitempty"`
+	ExpiresAt  string `json:"expiresAt,omitempty"`
+
+	OwnerRole string `json:"-"` // текущая роль владельца (заполняет FindToken)
+}
+
+func (p *Sqlite) CountUsers(ctx context.Context) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, `select count(*) from users`).Scan(&n)
+	return n, err
+}
+
+func (p *Sqlite) CreateUser(ctx context.Context, name, passwordHash, role string) (User, error) {
+	now := formatTime(time.Now())
+	res, err := p.db.ExecContext(ctx, `
+		insert into users (name, password_hash, role, created_at) values (?, ?, ?, ?)
+	`, name, passwordHash, role, now)
+	if err != nil {
+		return User{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	return User{ID: id, Name: name, PasswordHash: passwordHash, Role: role, CreatedAt: now}, nil
+}
+
+// UpdateUser меняет роль и пароль; пустые значения оставляют прежние.
+// При смене пароля сессии пользователя закрываются.
+func (p *Sqlite) UpdateUser(ctx context.Context, id int64, passwordHash, role string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		update users
+		   set role = case when ? = '' then role else ? end,
+		       password_hash = case when ? = '' then password_hash else ? end
+		 where id = ?
+	`, role, role, passwordHash, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if err := affectedOne(res); err != nil {
+		return err
+	}
+	if passwordHash != "" {
+		if _, err := tx.ExecContext(ctx, `delete from sessions where user_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountUsersWithRole — число пользователей с ролью role.
+func (p *Sqlite) CountUsersWithRole(ctx context.Context, role string) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, `select count(*) from users where role = ?`, role).Scan(&n)
+	return n, err
+}
+
+// DeleteUser удаляет пользователя вместе с его токенами и сессиями.
+func (p *Sqlite) DeleteUser(ctx context.Context, id int64) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range []string{
+		`delete from api_tokens where user_id = ?`,
+		`delete from sessions where user_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `delete from users where id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := affectedOne(res); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Sqlite) FindUserByName(ctx context.Context, name string) (User, error) {
+	return p.findUser(ctx, `where name = ?`, name)
+}
+
+func (p *Sqlite) FindUserByID(ctx context.Context, id int64) (User, error) {
+	return p.findUser(ctx, `where id = ?`, id)
+}
+
+func (p *Sqlite) findUser(ctx context.Context, where string, arg any) (User, error) {
+	var u User
+	err := p.db.QueryRowContext(ctx, `
+		select id, name, password_hash, role, created_at from users `+where, arg).
+		Scan(&u.ID, &u.Name, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Sqlite) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := p.db.QueryContext(ctx, `select id, name, password_hash, role, created_at from users order by name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// CreateToken сохраняет хэш токена; expires нулевое — бессрочный.
+func (p *Sqlite) CreateToken(ctx context.Context, userID int64, name, tokenHash, role string, expires time.Time) (APIToken, error) {
+	now := formatTime(time.Now())
+	exp := ""
+	if !expires.IsZero() {
+		exp = formatTime(expires)
+	}
+	res, err := p.db.ExecContext(ctx, `
+		insert into api_tokens (user_id, name, token_hash, role, created_at, expires_at)
+		values (?, ?, ?, ?, ?, ?)
+	`, userID, name, tokenHash, role, now, exp)
+	if err != nil {
+		return APIToken{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return APIToken{}, err
+	}
+	return APIToken{ID: id, UserID: userID, Name: name, Role: role, CreatedAt: now, ExpiresAt: exp}, nil
+}
+
+// FindToken ищет действующий токен по хэшу и отмечает его использование.
+func (p *Sqlite) FindToken(ctx context.Context, tokenHash string, now time.Time) (APIToken, error) {
+	var t APIToken
+	err := p.db.QueryRowContext(ctx, `
+		select t.id, t.user_id, u.name, t.name, t.role, t.created_at, t.last_used_at, t.expires_at, u.role
+		  from api_tokens t
+		  join users u on u.id = t.user_id
+		 where t.token_hash = ?
+		   and (t.expires_at = '' or t.expires_at > ?)
+	`, tokenHash, formatTime(now)).
+		Scan(&t.ID, &t.UserID, &t.UserName, &t.Name, &t.Role, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt, &t.OwnerRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, ErrNotFound
+	}
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	_, err = p.db.ExecContext(ctx, `update api_tokens set last_used_at = ? where id = ?`, formatTime(now), t.ID)
+	return t, err
+}
+
+// ListTokens — токены пользователя (userID == 0 — всех).
+func (p *Sqlite) ListTokens(ctx context.Context, userID int64) ([]APIToken, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		select t.id, t.user_id, u.name, t.name, t.role, t.created_at, t.last_used_at, t.expires_at
+		  from api_tokens t
+		  join users u on u.id = t.user_id
+		 where (? = 0 or t.user_id = ?)
+		 order by t.id
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.UserName, &t.Name, &t.Role, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// DeleteToken удаляет токен; userID != 0 — только если он принадлежит этому пользователю.
+func (p *Sqlite) DeleteToken(ctx context.Context, id, userID int64) error {
+	res, err := p.db.ExecContext(ctx, `delete from api_tokens where id = ? and (? = 0 or user_id = ?)`, id, userID, userID)
+	if err != nil {
+		return err
+	}
+	return affectedOne(res)
+}
+
+func (p *Sqlite) CreateSession(ctx context.Context, idHash string, userID int64, expires time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		insert into sessions (id_hash, user_id, created_at, expires_at) values (?, ?, ?, ?)
+	`, idHash, userID, formatTime(time.Now()), formatTime(expires))
+	return err
+}
+
+// FindSession — пользователь действующей сессии.
+func (p *Sqlite) FindSession(ctx context.Context, idHash string, now time.Time) (User, error) {
+	var u User
+	err := p.db.QueryRowContext(ctx, `
+		select u.id, u.name, u.password_hash, u.role, u.created_at
+		  from sessions s
+		  join users u on u.id = s.user_id
+		 where s.id_hash = ? and s.expires_at > ?
+	`, idHash, formatTime(now)).Scan(&u.ID, &u.Name, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Sqlite) DeleteSession(ctx context.Context, idHash string) error {
+	_, err := p.db.ExecContext(ctx, `delete from sessions where id_hash = ?`, idHash)
+	return err
+}
+
+// PruneSessions удаляет истёкшие сессии.
+func (p *Sqlite) PruneSessions(ctx context.Context, now time.Time) (int64, error) {
+	res, err := p.db.ExecContext(ctx, `delete from sessions where expires_at <= ?`, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func affectedOne(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DNSMapping — запомненная связь адрес -> домен из DNS-кэша роутера.
+type DNSMapping struct {
+	Router    string   `json:"router"`
+	IP        string   `json:"ip"`
+	Name      string   `json:"name"`
+	CNAMEs    []string `json:"cnames,omitempty"`
+	TTL       int64    `json:"ttlSeconds"` // остаток ttl при последнем опросе
+	FirstSeen string   `json:"firstSeen"`
+	LastSeen  string   `json:"lastSeen"`
+	ExpiresAt string   `json:"expiresAt"` // до этого момента запись была в кэше роутера
+}
+
+// SaveDNSMappings записывает связи одного опроса кэша; first_seen сохраняется.
+func (p *Sqlite) SaveDNSMappings(ctx context.Context, router string, at time.Time, items []DNSMapping) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		insert into dns_mappings (router, ip, name, cnames, ttl_seconds, first_seen, last_seen, expires_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?)
+		on conflict(router, ip, name) do update set
+			cnames      = excluded.cnames,
+			ttl_seconds = excluded.ttl_seconds,
+			last_seen   = excluded.last_seen,
+			expires_at  = max(expires_at, excluded.expires_at)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := formatTime(at)
+	for _, m := range items {
+		expires := formatTime(at.Add(time.Duration(m.TTL) * time.Second))
+		if _, err := stmt.ExecContext(ctx, router, m.IP, m.Name, strings.Join(m.CNAMEs, ","),
+			m.TTL, now, now, expires); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dnsLookupChunk — сколько адресов FindDNSMappings передаёт в одном запросе:
+// число параметров запроса у SQLite ограничено.
+const dnsLookupChunk = 500
+
+// FindDNSMappings — для каждого из ips самая свежая связь, истёкшая в кэше роутера
+// не раньше notBefore.
+func (p *Sqlite) FindDNSMappings(ctx context.Context, router string, ips []string, notBefore time.Time) (map[string]DNSMapping, error) {
+	out := map[string]DNSMapping{}
+	for chunk := range slices.Chunk(ips, dnsLookupChunk) {
+		if err := p.findDNSMappings(ctx, router, chunk, notBefore, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// findDNSMappings дописывает в out связи для части адресов (ips не пустой).
+func (p *Sqlite) findDNSMappings(ctx context.Context, router string, ips []string, notBefore time.Time, out map[string]DNSMapping) error {
+	args := []any{router, formatTime(notBefore)}
+	for _, ip := range ips {
+		args = append(args, ip)
+	}
+	rows, err := p.db.QueryContext(ctx, `
+		select router, ip, name, cnames, ttl_seconds, first_seen, last_seen, expires_at
+		  from dns_mappings
+		 where router = ? and expires_at >= ?
+		   and ip in (?`+strings.Repeat(", ?", len(ips)-1)+`)
+		 order by last_seen, expires_at
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanDNSMapping(rows)
+		if err != nil {
+			return err
+		}
+		out[m.IP] = m // по возрастанию: остаётся самая свежая
+	}
+	return rows.Err()
+}
+
+// FindDNSMappingsForIP — все запомненные имена адреса; router == "" — по всем роутерам.
+func (p *Sqlite) FindDNSMappingsForIP(ctx context.Context, router, ip string) ([]DNSMapping, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		select router, ip, name, cnames, ttl_seconds, first_seen, last_seen, expires_at
+		  from dns_mappings
+		 where (? = '' or router = ?) and ip = ?
+		 order by last_seen desc
+	`, router, router, ip)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []DNSMapping{}
+	for rows.Next() {
+		m, err := scanDNSMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func scanDNSMapping(rows interface{ Scan(...any) error }) (DNSMapping, error) {
+	var m DNSMapping
+	var cnames string
+	err := rows.Scan(&m.Router, &m.IP, &m.Name, &cnames, &m.TTL, &m.FirstSeen, &m.LastSeen, &m.ExpiresAt)
+	m.CNAMEs = splitList(cnames)
+	return m, err
+}
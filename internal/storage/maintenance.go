@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Prune-запросы: таблица и условие «запись старше ?» (у агрегатов ещё и гранулярность). Имена — константы, не пользовательский ввод.
+const (
+	pruneHistory = `connections where open = 0 and last_seen < ?`
+	pruneDomain  = `domain_conn_counts where updated_at < ?`
+	pruneDst     = `dst_conn_counts where updated_at < ?`
+
+	pruneTicks         = `collect_ticks where bucket = ? and bucket_start < ?`
+	pruneDomainRollups = `domain_conn_rollups where bucket = ? and bucket_start < ?`
+	pruneDstRollups    = `dst_conn_rollups where bucket = ? and bucket_start < ?`
+	pruneHostRollups   = `host_rollups where bucket = ? and bucket_start < ?`
+
+	pruneDNSMappings = `dns_mappings where expires_at < ?`
+	pruneAudit       = `audit_log where at < ?`
+	// последний снимок каждого листа остаётся, даже если он старый: периодические снимки без изменений не пишутся
+	pruneSnapshots = `list_snapshots where taken_at < ? and id not in (select max(id) from list_snapshots group by router, list_name)`
+)
+
+// PruneHistory удаляет закрытые сессии, последний раз виденные раньше before.
+func (p *Sqlite) PruneHistory(ctx context.Context, before time.Time, batch int) (int64, error) {
+	return p.pruneBatches(ctx, "connections", pruneHistory, batch, formatTime(before))
+}
+
+// PruneCounts удаляет счётчики доменов/адресов, которые не обновлялись с before.
+func (p *Sqlite) PruneCounts(ctx context.Context, before time.Time, batch int) (int64, error) {
+	n1, err := p.pruneBatches(ctx, "domain_conn_counts", pruneDomain, batch, formatTime(before))
+	if err != nil {
+		return n1, err
+	}
+	n2, err := p.pruneBatches(ctx, "dst_conn_counts", pruneDst, batch, formatTime(before))
+	return n1 + n2, err
+}
+
+// PruneDNSMappings удаляет связи адрес -> домен, истёкшие в кэше роутера раньше before.
+func (p *Sqlite) PruneDNSMappings(ctx context.Context, before time.Time, batch int) (int64, error) {
+	return p.pruneBatches(ctx, "dns_mappings", pruneDNSMappings, batch, formatTime(before))
+}
+
+// PruneAudit удаляет записи журнала изменений старше before.
+func (p *Sqlite) PruneAudit(ctx context.Context, before time.Time, batch int) (int64, error) {
+	return p.pruneBatches(ctx, "audit_log", pruneAudit, batch, formatTime(before))
+}
+
+// PruneSnapshots удаляет снимки address-листов старше before, кроме последнего снимка каждого листа.
+func (p *Sqlite) PruneSnapshots(ctx context.Context, before time.Time, batch int) (int64, error) {
+	return p.pruneBatches(ctx, "list_snapshots", pruneSnapshots, batch, formatTime(before))
+}
+
+// PruneRollups удаляет агрегаты гранулярности bucket, начавшиеся раньше before.
+func (p *Sqlite) PruneRollups(ctx context.Context, bucket Bucket, before time.Time, batch int) (int64, error) {
+	cutoff := formatTime(before)
+	var total int64
+	for _, t := range []struct{ table, from string }{
+		{"collect_ticks", pruneTicks},
+		{"domain_conn_rollups", pruneDomainRollups},
+		{"dst_conn_rollups", pruneDstRollups},
+		{"host_rollups", pruneHostRollups},
+	} {
+		n, err := p.pruneBatches(ctx, t.table, t.from, batch, bucket, cutoff)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// pruneBatches удаляет пачками по batch строк с короткой паузой между ними:
+// соединение с базой одно (SetMaxOpenConns(1)), и коллекторы не должны ждать его долго.
+func (p *Sqlite) pruneBatches(ctx context.Context, table, from string, batch int, args ...any) (int64, error) {
+	if batch <= 0 {
+		batch = 500
+	}
+
+	var total int64
+	for {
+		res, err := p.db.ExecContext(ctx,
+			`delete from `+table+` where rowid in (select rowid from `+from+` limit ?)`,
+			append(args, batch)...,
+		)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Compact возвращает свободные страницы файлу (нужен auto_vacuum=INCREMENTAL,
+// его включает migrate.Up) и сбрасывает WAL в основной файл.
+func (p *Sqlite) Compact(ctx context.Context, pages int) error {
+	// pragma не принимает параметры запроса; страница освобождается на каждом шаге выполнения,
+	// поэтому строки результата нужно дочитать (Exec делает только первый шаг)
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(`PRAGMA incremental_vacuum(%d)`, pages))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+type TableStats struct {
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+	Bytes  int64  `json:"bytes"` // таблица вместе с индексами
+	Oldest string `json:"oldest,omitempty"`
+}
+
+type DBStats struct {
+	FileBytes  int64        `json:"fileBytes"`
+	FreeBytes  int64        `json:"freeBytes"`
+	AutoVacuum string       `json:"autoVacuum"`
+	Tables     []TableStats `json:"tables"`
+}
+
+// statTables — таблицы для статистики и колонка времени, по которой видна самая старая запись.
+var statTables = []struct{ name, timeColumn string }{
+	{"connections", "first_seen"},
+	{"domain_conn_counts", "updated_at"},
+	{"dst_conn_counts", "updated_at"},
+	{"collect_ticks", "bucket_start"},
+	{"domain_conn_rollups", "bucket_start"},
+	{"dst_conn_rollups", "bucket_start"},
+	{"host_rollups", "bucket_start"},
+	{"dns_mappings", "first_seen"},
+	{"audit_log", "at"},
+	{"list_snapshots", "taken_at"},
+}
+
+func (p *Sqlite) Stats(ctx context.Context) (DBStats, error) {
+	var st DBStats
+
+	var pageSize, pageCount, freePages, autoVacuum int64
+	if err := p.db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return st, err
+	}
+	if err := p.db.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return st, err
+	}
+	if err := p.db.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return st, err
+	}
+	if err := p.db.QueryRowContext(ctx, `PRAGMA auto_vacuum`).Scan(&autoVacuum); err != nil {
+		return st, err
+	}
+	st.FileBytes = pageSize * pageCount
+	st.FreeBytes = pageSize * freePages
+	st.AutoVacuum = map[int64]string{0: "none", 1: "full", 2: "incremental"}[autoVacuum]
+
+	// размер по dbstat; если модуль недоступен — оставляем нули
+	bytesByTable := map[string]int64{}
+	if rows, err := p.db.QueryContext(ctx, `
+		select s.tbl_name, sum(d.pgsize)
+		  from dbstat d
+		  join sqlite_schema s on s.name = d.name
+		 group by s.tbl_name
+	`); err == nil {
+		for rows.Next() {
+			var name string
+			var n int64
+			if rows.Scan(&name, &n) == nil {
+				bytesByTable[name] = n
+			}
+		}
+		_ = rows.Close()
+	}
+
+	for _, t := range statTables {
+		ts := TableStats{Name: t.name, Bytes: bytesByTable[t.name]}
+		var oldest *string
+		if err := p.db.QueryRowContext(ctx,
+			`select count(*), min(`+t.timeColumn+`) from `+t.name,
+		).Scan(&ts.Rows, &oldest); err != nil {
+			return st, err
+		}
+		if oldest != nil {
+			ts.Oldest = *oldest
+		}
+		st.Tables = append(st.Tables, ts)
+	}
+	return st, nil
+}
@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Bucket — гранулярность агрегатов (rollup) числа соединений.
+type Bucket string
+
+const (
+	BucketMinute Bucket = "minute"
+	BucketHour   Bucket = "hour"
+	BucketDay    Bucket = "day"
+)
+
+var Buckets = []Bucket{BucketMinute, BucketHour, BucketDay}
+
+func ParseBucket(s string) (Bucket, error) {
+	for _, b := range Buckets {
+		if string(b) == s {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("unknown bucket %q (want minute, hour or day)", s)
+}
+
+// Truncate — начало бакета (UTC).
+func (b Bucket) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketMinute:
+		return t.Truncate(time.Minute)
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Traffic — приращения счётчиков conntrack: orig — от клиента (upload), repl — к клиенту (download).
+type Traffic struct {
+	OrigBytes   int64 `json:"origBytes"`
+	ReplBytes   int64 `json:"replBytes"`
+	OrigPackets int64 `json:"origPackets"`
+	ReplPackets int64 `json:"replPackets"`
+}
+
+func (t *Traffic) Add(o Traffic) {
+	t.OrigBytes += o.OrigBytes
+	t.ReplBytes += o.ReplBytes
+	t.OrigPackets += o.OrigPackets
+	t.ReplPackets += o.ReplPackets
+}
+
+// HostCount — соединения и трафик одного LAN-клиента за тик.
+type HostCount struct {
+	SrcIP string
+	Count int64
+	Traffic
+}
+
+// AddRollupSample добавляет в минутные/часовые/дневные агрегаты один тик коллектора.
+func (p *Sqlite) AddRollupSample(ctx context.Context, router string, at time.Time, domains []DomainCount, dsts []DstCount, hosts []HostCount) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	tickStmt, err := tx.PrepareContext(ctx, `
+		insert into collect_ticks (router, bucket, bucket_start, ticks)
+		values (?, ?, ?, 1)
+		on conflict(router, bucket, bucket_start) do update set
+			ticks = ticks + 1
+	`)
+	if err != nil {
+		return err
+	}
+	defer tickStmt.Close()
+
+	domainStmt, err := tx.PrepareContext(ctx, `
+		insert into domain_conn_rollups (router, bucket, bucket_start, dst_dns,
+		                                 min_connections, max_connections, sum_connections, samples,
+		                                 orig_bytes, repl_bytes, orig_packets, repl_packets)
+		values (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		on conflict(router, bucket, dst_dns, bucket_start) do update set
+			min_connections = min(min_connections, excluded.min_connections),
+			max_connections = max(max_connections, excluded.max_connections),
+			sum_connections = sum_connections + excluded.sum_connections,
+			samples = samples + 1,
+			`+addTraffic+`
+	`)
+	if err != nil {
+		return err
+	}
+	defer domainStmt.Close()
+
+	dstStmt, err := tx.PrepareContext(ctx, `
+		insert into dst_conn_rollups (router, bucket, bucket_start, dst_ip, dst_dns,
+		                              min_connections, max_connections, sum_connections, samples,
+		                              orig_bytes, repl_bytes, orig_packets, repl_packets)
+		values (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		on conflict(router, bucket, dst_ip, dst_dns, bucket_start) do update set
+			min_connections = min(min_connections, excluded.min_connections),
+			max_connections = max(max_connections, excluded.max_connections),
+			sum_connections = sum_connections + excluded.sum_connections,
+			samples = samples + 1,
+			`+addTraffic+`
+	`)
+	if err != nil {
+		return err
+	}
+	defer dstStmt.Close()
+
+	hostStmt, err := tx.PrepareContext(ctx, `
+		insert into host_rollups (router, bucket, bucket_start, src_ip,
+		                          min_connections, max_connections, sum_connections, samples,
+		                          orig_bytes, repl_bytes, orig_packets, repl_packets)
+		values (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		on conflict(router, bucket, src_ip, bucket_start) do update set
+			min_connections = min(min_connections, excluded.min_connections),
+			max_connections = max(max_connections, excluded.max_connections),
+			sum_connections = sum_connections + excluded.sum_connections,
+			samples = samples + 1,
+			`+addTraffic+`
+	`)
+	if err != nil {
+		return err
+	}
+	defer hostStmt.Close()
+
+	for _, b := range Buckets {
+		start := formatTime(b.Truncate(at))
+		if _, err := tickStmt.ExecContext(ctx, router, b, start); err != nil {
+			return err
+		}
+		for _, c := range domains {
+			if _, err := domainStmt.ExecContext(ctx, router, b, start, c.DstDNS, c.Count, c.Count, c.Count,
+				c.OrigBytes, c.ReplBytes, c.OrigPackets, c.ReplPackets); err != nil {
+				return err
+			}
+		}
+		for _, c := range dsts {
+			if _, err := dstStmt.ExecContext(ctx, router, b, start, c.DstIP, c.DstDNS, c.Count, c.Count, c.Count,
+				c.OrigBytes, c.ReplBytes, c.OrigPackets, c.ReplPackets); err != nil {
+				return err
+			}
+		}
+		for _, c := range hosts {
+			if _, err := hostStmt.ExecContext(ctx, router, b, start, c.SrcIP, c.Count, c.Count, c.Count,
+				c.OrigBytes, c.ReplBytes, c.OrigPackets, c.ReplPackets); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+const addTraffic = `
+			orig_bytes   = orig_bytes + excluded.orig_bytes,
+			repl_bytes   = repl_bytes + excluded.repl_bytes,
+			orig_packets = orig_packets + excluded.orig_packets,
+			repl_packets = repl_packets + excluded.repl_packets`
+
+// SeriesPoint — один бакет ряда. Тики, в которых соединений не было, считаются нулями.
+type SeriesPoint struct {
+	Start string  `json:"t"`
+	Min   int64   `json:"min"`
+	Max   int64   `json:"max"`
+	Avg   float64 `json:"avg"`
+	Ticks int64   `json:"ticks"`
+	Traffic
+}
+
+// FindDomainSeries — ряд числа активных соединений домена (точное имя) за [from, to].
+func (p *Sqlite) FindDomainSeries(ctx context.Context, router string, bucket Bucket, domain string, from, to time.Time) ([]SeriesPoint, error) {
+	return p.findSeries(ctx, `
+		select t.bucket_start, r.min_connections, r.max_connections, r.sum_connections, r.samples, t.ticks,
+		       r.orig_bytes, r.repl_bytes, r.orig_packets, r.repl_packets
+		  from collect_ticks t
+		  left join domain_conn_rollups r
+		    on r.router = t.router and r.bucket = t.bucket and r.bucket_start = t.bucket_start
+		   and r.dst_dns = ?
+		 where t.router = ? and t.bucket = ? and t.bucket_start >= ? and t.bucket_start <= ?
+		 order by t.bucket_start
+	`, domain, router, bucket, formatTime(bucket.Truncate(from)), formatTime(to))
+}
+
+// FindDstSeries — то же для адреса назначения; domain == "" — по всем доменам этого адреса
+// (min/max тогда приблизительные: берутся по отдельным парам ip+домен).
+func (p *Sqlite) FindDstSeries(ctx context.Context, router string, bucket Bucket, dstIP, domain string, from, to time.Time) ([]SeriesPoint, error) {
+	return p.findSeries(ctx, `
+		select t.bucket_start, r.min_connections, r.max_connections, r.sum_connections, r.samples, t.ticks,
+		       r.orig_bytes, r.repl_bytes, r.orig_packets, r.repl_packets
+		  from collect_ticks t
+		  left join (
+		        select bucket, bucket_start,
+		               min(min_connections) as min_connections,
+		               max(max_connections) as max_connections,
+		               sum(sum_connections) as sum_connections,
+		               max(samples)         as samples,
+		               sum(orig_bytes)      as orig_bytes,
+		               sum(repl_bytes)      as repl_bytes,
+		               sum(orig_packets)    as orig_packets,
+		               sum(repl_packets)    as repl_packets
+		          from dst_conn_rollups
+		         where router = ? and dst_ip = ? and (? = '' or dst_dns = ?)
+		         group by bucket, bucket_start
+		       ) r
+		    on r.bucket = t.bucket and r.bucket_start = t.bucket_start
+		 where t.router = ? and t.bucket = ? and t.bucket_start >= ? and t.bucket_start <= ?
+		 order by t.bucket_start
+	`, router, dstIP, domain, domain, router, bucket, formatTime(bucket.Truncate(from)), formatTime(to))
+}
+
+func (p *Sqlite) findSeries(ctx context.Context, query string, args ...any) ([]SeriesPoint, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SeriesPoint{}
+	for rows.Next() {
+		var (
+			pt                        SeriesPoint
+			minC, maxC, sumC, samples *int64
+			ob, rb, op, rp            *int64
+		)
+		if err := rows.Scan(&pt.Start, &minC, &maxC, &sumC, &samples, &pt.Ticks, &ob, &rb, &op, &rp); err != nil {
+			return nil, err
+		}
+		if samples != nil {
+			pt.Max = *maxC
+			pt.Traffic = Traffic{OrigBytes: *ob, ReplBytes: *rb, OrigPackets: *op, ReplPackets: *rp}
+			// домен был виден не во всех тиках — минимум 0
+			if *samples >= pt.Ticks {
+				pt.Min = *minC
+			}
+			if pt.Ticks > 0 {
+				pt.Avg = float64(*sumC) / float64(pt.Ticks)
+			}
+		}
+		out = append(out, pt)
+	}
+	return out, rows.Err()
+}
+
+// trafficSources — по чему ранжировать трафик: таблица агрегатов и колонка ключа.
+var trafficSources = map[string]struct{ table, key string }{
+	"domain": {"domain_conn_rollups", "dst_dns"},
+	"dst":    {"dst_conn_rollups", "dst_ip"},
+	"host":   {"host_rollups", "src_ip"},
+}
+
+// TrafficRank — суммарный трафик домена, адреса назначения или LAN-клиента.
+type TrafficRank struct {
+	Router string `json:"router"`
+	Key    string `json:"key"`
+	Traffic
+}
+
+// TopTraffic возвращает limit самых «тяжёлых» по байтам (orig+repl) ключей by
+// ("domain", "dst" или "host") в агрегатах гранулярности bucket начиная с from.
+func (p *Sqlite) TopTraffic(ctx context.Context, router, by string, bucket Bucket, from time.Time, limit int) ([]TrafficRank, error) {
+	src, ok := trafficSources[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown traffic grouping %q (want domain, dst or host)", by)
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		select router, `+src.key+`,
+		       sum(orig_bytes), sum(repl_bytes), sum(orig_packets), sum(repl_packets)
+		  from `+src.table+`
+		 where (? = '' or router = ?) and bucket = ? and bucket_start >= ?
+		 group by router, `+src.key+`
+		 order by sum(orig_bytes) + sum(repl_bytes) desc
+		 limit ?
+	`, router, router, bucket, formatTime(bucket.Truncate(from)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []TrafficRank{}
+	for rows.Next() {
+		var t TrafficRank
+		if err := rows.Scan(&t.Router, &t.Key, &t.OrigBytes, &t.ReplBytes, &t.OrigPackets, &t.ReplPackets); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// FindHostTraffic — трафик LAN-клиентов по src_ip в агрегатах bucket начиная с from.
+func (p *Sqlite) FindHostTraffic(ctx context.Context, router string, bucket Bucket, from time.Time) (map[string]Traffic, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		select src_ip, sum(orig_bytes), sum(repl_bytes), sum(orig_packets), sum(repl_packets)
+		  from host_rollups
+		 where router = ? and bucket = ? and bucket_start >= ?
+		 group by src_ip
+	`, router, bucket, formatTime(bucket.Truncate(from)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]Traffic{}
+	for rows.Next() {
+		var ip string
+		var t Traffic
+		if err := rows.Scan(&ip, &t.OrigBytes, &t.ReplBytes, &t.OrigPackets, &t.ReplPackets); err != nil {
+			return nil, err
+		}
+		out[ip] = t
+	}
+	return out, rows.Err()
+}
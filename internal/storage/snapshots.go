@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// SnapshotEntry — статическая запись address-листа в снимке.
+type SnapshotEntry struct {
+	Family   string `json:"family"`
+	Address  string `json:"address"`
+	Disabled bool   `json:"disabled"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ListSnapshot — снимок одного address-листа роутера; Entries заполняется только при чтении по id.
+type ListSnapshot struct {
+	ID         int64           `json:"id"`
+	Router     string          `json:"router"`
+	List       string          `json:"list"`
+	TakenAt    string          `json:"takenAt"`
+	Reason     string          `json:"reason"`
+	Actor      string          `json:"actor,omitempty"`
+	EntryCount int             `json:"entryCount"`
+	Entries    []SnapshotEntry `json:"entries,omitempty"`
+}
+
+// InsertListSnapshot сохраняет снимок; при skipSame снимок, совпадающий с последним
+// снимком того же листа, не сохраняется и возвращается последний (ok == false).
+func (p *Sqlite) InsertListSnapshot(ctx context.Context, s ListSnapshot, skipSame bool) (ListSnapshot, bool, error) {
+	entries, err := json.Marshal(s.Entries)
+	if err != nil {
+		return ListSnapshot{}, false, err
+	}
+
+	if skipSame {
+		var last ListSnapshot
+		var lastEntries string
+		err := p.db.QueryRowContext(ctx, `
+			select id, router, list_name, taken_at, reason, actor, entry_count, entries
+			  from list_snapshots
+			 where router = ? and list_name = ?
+			 order by id desc
+			 limit 1
+		`, s.Router, s.List).Scan(&last.ID, &last.Router, &last.List, &last.TakenAt, &last.Reason, &last.Actor,
+			&last.EntryCount, &lastEntries)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return ListSnapshot{}, false, err
+		}
+		if err == nil && lastEntries == string(entries) {
+			return last, false, nil
+		}
+	}
+
+	if s.TakenAt == "" {
+		s.TakenAt = formatTime(time.Now())
+	}
+	s.EntryCount = len(s.Entries)
+	res, err := p.db.ExecContext(ctx, `
+		insert into list_snapshots (router, list_name, taken_at, reason, actor, entry_count, entries)
+		values (?, ?, ?, ?, ?, ?, ?)
+	`, s.Router, s.List, s.TakenAt, s.Reason, s.Actor, s.EntryCount, string(entries))
+	if err != nil {
+		return ListSnapshot{}, false, err
+	}
+	if s.ID, err = res.LastInsertId(); err != nil {
+		return ListSnapshot{}, false, err
+	}
+	return s, true, nil
+}
+
+// FindListSnapshots — снимки роутера без записей, новые сверху; list == "" — все листы.
+func (p *Sqlite) FindListSnapshots(ctx context.Context, router, list string, limit int) ([]ListSnapshot, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := p.db.QueryContext(ctx, `
+		select id, router, list_name, taken_at, reason, actor, entry_count
+		  from list_snapshots
+		 where router = ?
+		   and (? = '' or list_name = ?)
+		 order by id desc
+		 limit ?
+	`, router, list, list, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ListSnapshot
+	for rows.Next() {
+		var s ListSnapshot
+		if err := rows.Scan(&s.ID, &s.Router, &s.List, &s.TakenAt, &s.Reason, &s.Actor, &s.EntryCount); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (p *Sqlite) GetListSnapshot(ctx context.Context, id int64) (ListSnapshot, error) {
+	var s ListSnapshot
+	var entries string
+	err := p.db.QueryRowContext(ctx, `
+		select id, router, list_name, taken_at, reason, actor, entry_count, entries
+		  from list_snapshots
+		 where id = ?
+	`, id).Scan(&s.ID, &s.Router, &s.List, &s.TakenAt, &s.Reason, &s.Actor, &s.EntryCount, &entries)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ListSnapshot{}, ErrNotFound
+	}
+	if err != nil {
+		return ListSnapshot{}, err
+	}
+	if err := json.Unmarshal([]byte(entries), &s.Entries); err != nil {
+		return ListSnapshot{}, err
+	}
+	return s, nil
+}
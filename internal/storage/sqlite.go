@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"mikrotik-parser-go/internal/domain"
@@ -50,11 +51,16 @@ func New(ctx context.Context, dsn string) (*Sqlite, error) {
 
 func (p *Sqlite) Close() { _ = p.db.Close() }
 
-func (p *Sqlite) SaveAll(ctx context.Context, items []domain.Connection) error {
-	if len(items) == 0 {
-		return nil
-	}
+// timeFormat — фиксированная ширина (миллисекунды всегда), чтобы строки сравнивались как время;
+// совпадает с strftime('%Y-%m-%dT%H:%M:%fZ') в SQL.
+const timeFormat = "2006-01-02T15:04:05.000Z"
 
+func formatTime(t time.Time) string { return t.UTC().Format(timeFormat) }
+
+// SaveAll записывает наблюдения одного тика как сессии: открытая сессия с тем же
+// 5-tuple продлевается (last_seen), новая вставляется, а открытые сессии роутера,
+// которых в тике нет, закрываются.
+func (p *Sqlite) SaveAll(ctx context.Context, router string, items []domain.Connection) error {
 	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -62,37 +68,56 @@ func (p *Sqlite) SaveAll(ctx context.Context, items []domain.Connection) error {
 	defer func() { _ = tx.Rollback() }()
 
 	stmt, err := tx.PrepareContext(ctx, `
-		insert into connections (src_ip, dst_ip, dst_dns, host_name, created_at)
-		values (?, ?, ?, ?, ?)
+		insert into connections (router, protocol, src_ip, src_port, dst_ip, dst_port, dst_dns, host_name,
+		                         first_seen, last_seen, open, orig_bytes, repl_bytes)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+		on conflict(router, protocol, src_ip, src_port, dst_ip, dst_port) where open = 1 do update set
+			last_seen  = excluded.last_seen,
+			orig_bytes = excluded.orig_bytes,
+			repl_bytes = excluded.repl_bytes,
+			dst_dns   = case when excluded.dst_dns != '' then excluded.dst_dns else connections.dst_dns end,
+			host_name = case when excluded.host_name != '' then excluded.host_name else connections.host_name end
 	`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	now := time.Now().UTC().Format(time.RFC3339Nano)
+	now := formatTime(time.Now())
 	for _, it := range items {
-		if _, err := stmt.ExecContext(ctx, it.SrcIP, it.DstIP, it.DstDNS, it.HostName, now); err != nil {
+		if _, err := stmt.ExecContext(ctx,
+			router, it.Protocol, it.SrcIP, it.SrcPort, it.DstIP, it.DstPort, it.DstDNS, it.HostName, now, now,
+			it.OrigBytes, it.ReplBytes,
+		); err != nil {
 			return err
 		}
 	}
 
+	if _, err := tx.ExecContext(ctx, `
+		update connections set open = 0
+		 where router = ? and open = 1 and last_seen < ?
+	`, router, now); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (p *Sqlite) FindByDstDNSLike(ctx context.Context, q string) ([]domain.Connection, error) {
+// FindByDstDNSLike ищет соединения по подстроке домена; router == "" — по всем роутерам.
+func (p *Sqlite) FindByDstDNSLike(ctx context.Context, router, q string) ([]domain.Connection, error) {
 	// Sqlite DISTINCT ON (dst_ip) -> SQLite row_number() over(partition by dst_ip ...)
 	rows, err := p.db.QueryContext(ctx, `
-		select src_ip, dst_ip, dst_dns, host_name, created_at
+		select router, src_ip, dst_ip, dst_dns, host_name, first_seen
 		from (
-			select src_ip, dst_ip, dst_dns, host_name, created_at,
-				   row_number() over (partition by dst_ip order by created_at desc) as rn
+			select router, src_ip, dst_ip, dst_dns, host_name, first_seen,
+				   row_number() over (partition by router, dst_ip order by last_seen desc) as rn
 			from connections
-			where lower(dst_dns) like '%' || lower(?) || '%'
+			where (? = '' or router = ?)
+			  and lower(dst_dns) like '%' || lower(?) || '%'
 		)
 		where rn = 1
-		order by dst_ip
-	`, q)
+		order by router, dst_ip
+	`, router, router, q)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +127,7 @@ func (p *Sqlite) FindByDstDNSLike(ctx context.Context, q string) ([]domain.Conne
 	for rows.Next() {
 		var c domain.Connection
 		var createdAt string
-		if err := rows.Scan(&c.SrcIP, &c.DstIP, &c.DstDNS, &c.HostName, &createdAt); err != nil {
+		if err := rows.Scan(&c.Router, &c.SrcIP, &c.DstIP, &c.DstDNS, &c.HostName, &createdAt); err != nil {
 			return nil, err
 		}
 		c.CreatedAt = createdAt
@@ -111,20 +136,122 @@ func (p *Sqlite) FindByDstDNSLike(ctx context.Context, q string) ([]domain.Conne
 	return out, rows.Err()
 }
 
+// HistoryFilter — пустые поля не фильтруют. From/To выбирают сессии,
+// пересекающиеся с интервалом [From, To].
+type HistoryFilter struct {
+	Router string
+	From   time.Time
+	To     time.Time
+	SrcIP  string
+	DstIP  string
+	Domain string // подстрока dst_dns
+	Limit  int
+}
+
+func (p *Sqlite) FindHistory(ctx context.Context, f HistoryFilter) ([]domain.Session, error) {
+	var from, to string
+	if !f.From.IsZero() {
+		from = formatTime(f.From)
+	}
+	if !f.To.IsZero() {
+		to = formatTime(f.To)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		select router, protocol, src_ip, src_port, dst_ip, dst_port, dst_dns, host_name, first_seen, last_seen, open,
+		       orig_bytes, repl_bytes
+		  from connections
+		 where (? = '' or router = ?)
+		   and (? = '' or last_seen >= ?)
+		   and (? = '' or first_seen <= ?)
+		   and (? = '' or src_ip = ?)
+		   and (? = '' or dst_ip = ?)
+		   and (? = '' or lower(dst_dns) like '%' || lower(?) || '%')
+		 order by last_seen desc
+		 limit ?
+	`, f.Router, f.Router, from, from, to, to, f.SrcIP, f.SrcIP, f.DstIP, f.DstIP, f.Domain, f.Domain, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Session
+	for rows.Next() {
+		var s domain.Session
+		if err := rows.Scan(&s.Router, &s.Protocol, &s.SrcIP, &s.SrcPort, &s.DstIP, &s.DstPort,
+			&s.DstDNS, &s.HostName, &s.FirstSeen, &s.LastSeen, &s.Open, &s.OrigBytes, &s.ReplBytes); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// FindTopDomainsByHost — для каждого src_ip до perHost доменов с наибольшим числом сессий,
+// видимых начиная с from.
+func (p *Sqlite) FindTopDomainsByHost(ctx context.Context, router string, from time.Time, perHost int) (map[string][]domain.HostDomain, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		select src_ip, dst_dns, sessions, bytes
+		from (
+			select src_ip, dst_dns, count(*) as sessions, sum(orig_bytes + repl_bytes) as bytes,
+				   row_number() over (partition by src_ip order by count(*) desc, sum(orig_bytes + repl_bytes) desc) as rn
+			from connections
+			where router = ? and last_seen >= ? and dst_dns != ''
+			group by src_ip, dst_dns
+		)
+		where rn <= ?
+		order by src_ip, rn
+	`, router, formatTime(from), perHost)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]domain.HostDomain{}
+	for rows.Next() {
+		var ip string
+		var d domain.HostDomain
+		if err := rows.Scan(&ip, &d.DstDNS, &d.Sessions, &d.Bytes); err != nil {
+			return nil, err
+		}
+		out[ip] = append(out[ip], d)
+	}
+	return out, rows.Err()
+}
+
+// splitList — обратная операция к strings.Join(list, ","); "" — пустой список.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// Traffic в счётчиках заполняет коллектор для агрегатов; в *_conn_counts он не хранится.
 type DomainCount struct {
+	Router    string
 	DstDNS    string
 	Count     int64
 	UpdatedAt string `json:"updatedAt,omitempty"`
+	Traffic   `json:"-"`
 }
 
 type DstCount struct {
-	DstIP     string
-	DstDNS    string
-	Count     int64
-	UpdatedAt string `json:"updatedAt,omitempty"`
+	Router     string
+	DstIP      string
+	DstDNS     string
+	CNAMEs     []string // цепочка CNAME от DstDNS к адресу
+	OtherNames []string // другие имена того же адреса
+	Count      int64
+	UpdatedAt  string `json:"updatedAt,omitempty"`
+	Traffic    `json:"-"`
 }
 
-func (p *Sqlite) UpsertDomainCounts(ctx context.Context, counts []DomainCount) error {
+func (p *Sqlite) UpsertDomainCounts(ctx context.Context, router string, counts []DomainCount) error {
 	if len(counts) == 0 {
 		return nil
 	}
@@ -136,9 +263,9 @@ func (p *Sqlite) UpsertDomainCounts(ctx context.Context, counts []DomainCount) e
 	defer func() { _ = tx.Rollback() }()
 
 	stmt, err := tx.PrepareContext(ctx, `
-		insert into domain_conn_counts (dst_dns, active_connections, updated_at)
-		values (?, ?, strftime('%Y-%m-%dT%H:%M:%fZ','now'))
-		on conflict(dst_dns) do update set
+		insert into domain_conn_counts (router, dst_dns, active_connections, updated_at)
+		values (?, ?, ?, strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+		on conflict(router, dst_dns) do update set
 			active_connections = excluded.active_connections,
 			updated_at = excluded.updated_at
 	`)
@@ -148,7 +275,7 @@ func (p *Sqlite) UpsertDomainCounts(ctx context.Context, counts []DomainCount) e
 	defer stmt.Close()
 
 	for _, c := range counts {
-		if _, err := stmt.ExecContext(ctx, c.DstDNS, c.Count); err != nil {
+		if _, err := stmt.ExecContext(ctx, router, c.DstDNS, c.Count); err != nil {
 			return err
 		}
 	}
@@ -156,13 +283,14 @@ func (p *Sqlite) UpsertDomainCounts(ctx context.Context, counts []DomainCount) e
 	return tx.Commit()
 }
 
-func (p *Sqlite) FindDomainCountsLike(ctx context.Context, q string) ([]DomainCount, error) {
+func (p *Sqlite) FindDomainCountsLike(ctx context.Context, router, q string) ([]DomainCount, error) {
 	rows, err := p.db.QueryContext(ctx, `
-		select dst_dns, active_connections, updated_at
+		select router, dst_dns, active_connections, updated_at
 		  from domain_conn_counts
-		 where lower(dst_dns) like '%' || lower(?) || '%'
+		 where (? = '' or router = ?)
+		   and lower(dst_dns) like '%' || lower(?) || '%'
 		 order by active_connections desc
-	`, q)
+	`, router, router, q)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +299,7 @@ func (p *Sqlite) FindDomainCountsLike(ctx context.Context, q string) ([]DomainCo
 	var out []DomainCount
 	for rows.Next() {
 		var dc DomainCount
-		if err := rows.Scan(&dc.DstDNS, &dc.Count, &dc.UpdatedAt); err != nil {
+		if err := rows.Scan(&dc.Router, &dc.DstDNS, &dc.Count, &dc.UpdatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, dc)
@@ -179,7 +307,7 @@ func (p *Sqlite) FindDomainCountsLike(ctx context.Context, q string) ([]DomainCo
 	return out, rows.Err()
 }
 
-func (p *Sqlite) UpsertDstCounts(ctx context.Context, counts []DstCount) error {
+func (p *Sqlite) UpsertDstCounts(ctx context.Context, router string, counts []DstCount) error {
 	if len(counts) == 0 {
 		return nil
 	}
@@ -191,9 +319,11 @@ func (p *Sqlite) UpsertDstCounts(ctx context.Context, counts []DstCount) error {
 	defer func() { _ = tx.Rollback() }()
 
 	stmt, err := tx.PrepareContext(ctx, `
-		insert into dst_conn_counts (dst_ip, dst_dns, active_connections, updated_at)
-		values (?, ?, ?, strftime('%Y-%m-%dT%H:%M:%fZ','now'))
-		on conflict(dst_ip, dst_dns) do update set
+		insert into dst_conn_counts (router, dst_ip, dst_dns, cnames, other_names, active_connections, updated_at)
+		values (?, ?, ?, ?, ?, ?, strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+		on conflict(router, dst_ip, dst_dns) do update set
+			cnames = excluded.cnames,
+			other_names = excluded.other_names,
 			active_connections = excluded.active_connections,
 			updated_at = excluded.updated_at
 	`)
@@ -203,7 +333,8 @@ func (p *Sqlite) UpsertDstCounts(ctx context.Context, counts []DstCount) error {
 	defer stmt.Close()
 
 	for _, c := range counts {
-		if _, err := stmt.ExecContext(ctx, c.DstIP, c.DstDNS, c.Count); err != nil {
+		if _, err := stmt.ExecContext(ctx, router, c.DstIP, c.DstDNS,
+			strings.Join(c.CNAMEs, ","), strings.Join(c.OtherNames, ","), c.Count); err != nil {
 			return err
 		}
 	}
@@ -211,13 +342,14 @@ func (p *Sqlite) UpsertDstCounts(ctx context.Context, counts []DstCount) error {
 	return tx.Commit()
 }
 
-func (p *Sqlite) FindDstCountsLike(ctx context.Context, q string) ([]DstCount, error) {
+func (p *Sqlite) FindDstCountsLike(ctx context.Context, router, q string) ([]DstCount, error) {
 	rows, err := p.db.QueryContext(ctx, `
-		select dst_ip, dst_dns, active_connections, updated_at
+		select router, dst_ip, dst_dns, cnames, other_names, active_connections, updated_at
 		  from dst_conn_counts
-		 where lower(dst_dns) like '%' || lower(?) || '%'
+		 where (? = '' or router = ?)
+		   and (lower(dst_dns) like '%' || lower(?) || '%' or cnames like '%' || lower(?) || '%')
 		 order by active_connections desc
-	`, q)
+	`, router, router, q, q)
 	if err != nil {
 		return nil, err
 	}
@@ -226,9 +358,12 @@ func (p *Sqlite) FindDstCountsLike(ctx context.Context, q string) ([]DstCount, e
 	var out []DstCount
 	for rows.Next() {
 		var dc DstCount
-		if err := rows.Scan(&dc.DstIP, &dc.DstDNS, &dc.Count, &dc.UpdatedAt); err != nil {
+		var cnames, others string
+		if err := rows.Scan(&dc.Router, &dc.DstIP, &dc.DstDNS, &cnames, &others, &dc.Count, &dc.UpdatedAt); err != nil {
 			return nil, err
 		}
+		dc.CNAMEs = splitList(cnames)
+		dc.OtherNames = splitList(others)
 		out = append(out, dc)
 	}
 	return out, rows.Err()